@@ -0,0 +1,204 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+)
+
+// moveAction is the action posted to move clouds programmatically. Its value
+// is a []position indexed like the clouds.
+const moveAction = "cloud/move"
+
+const (
+	// relaxIterations is the number of relaxation steps used to compute an
+	// arranged layout.
+	relaxIterations = 300
+
+	// arrangeFrames and arrangeFrameInterval define the animation from the
+	// current layout to the arranged one.
+	arrangeFrames        = 36
+	arrangeFrameInterval = 16 * time.Millisecond
+)
+
+// relaxLayout spreads clouds apart with a simple force-directed relaxation:
+// overlapping clouds push each other away while a weak pull keeps them
+// gathered around the center of the w by h viewport. Clouds marked as fixed
+// don't move but still push the others away. The result keeps every movable
+// cloud inside the viewport.
+func relaxLayout(start []position, fixed []bool, w, h int) []position {
+	n := len(start)
+	if n == 0 {
+		return nil
+	}
+
+	// Aim for clouds evenly sharing the viewport area, without ever letting
+	// them touch nor drift too far apart.
+	spacing := math.Sqrt(float64(w*h)/float64(n)) * 0.8
+	spacing = math.Max(spacing, cloudSize*1.2)
+	spacing = math.Min(spacing, cloudSize*3)
+
+	half := float64(cloudSize) / 2
+	cx, cy := float64(w)/2, float64(h)/2
+	xs := make([]float64, n)
+	ys := make([]float64, n)
+	for i, p := range start {
+		xs[i] = float64(p.X) + half
+		ys[i] = float64(p.Y) + half
+	}
+
+	for range relaxIterations {
+		for i := range n {
+			for j := i + 1; j < n; j++ {
+				dx, dy := xs[j]-xs[i], ys[j]-ys[i]
+				d := math.Hypot(dx, dy)
+				if d >= spacing {
+					continue
+				}
+				if d == 0 {
+					// Clouds on top of each other have no direction to be
+					// pushed in: pick one that depends on their indexes.
+					angle := float64(i*n+j) * math.Pi * (3 - math.Sqrt(5))
+					dx, dy, d = math.Cos(angle), math.Sin(angle), 1
+				}
+
+				if fixed[i] && fixed[j] {
+					continue
+				}
+
+				// Split the push between both clouds, or give it all to the
+				// one that can move.
+				push := (spacing - d) / 2
+				pushI, pushJ := push, push
+				if fixed[i] {
+					pushI, pushJ = 0, push*2
+				} else if fixed[j] {
+					pushI, pushJ = push*2, 0
+				}
+				xs[i], ys[i] = xs[i]-dx/d*pushI, ys[i]-dy/d*pushI
+				xs[j], ys[j] = xs[j]+dx/d*pushJ, ys[j]+dy/d*pushJ
+			}
+		}
+
+		for i := range n {
+			if fixed[i] {
+				continue
+			}
+			xs[i] += (cx - xs[i]) * 0.01
+			ys[i] += (cy - ys[i]) * 0.01
+			xs[i] = math.Min(math.Max(xs[i], half), math.Max(float64(w)-half, half))
+			ys[i] = math.Min(math.Max(ys[i], half), math.Max(float64(h)-half, half))
+		}
+	}
+
+	arranged := make([]position, n)
+	for i := range arranged {
+		arranged[i] = position{
+			X: int(math.Round(xs[i] - half)),
+			Y: int(math.Round(ys[i] - half)),
+		}
+	}
+	return arranged
+}
+
+// interpolate returns the layout at progress t, between 0 and 1, of the
+// transition from one layout to another of the same length.
+func interpolate(from, to []position, t float64) []position {
+	frame := make([]position, len(to))
+	for i := range frame {
+		frame[i] = position{
+			X: from[i].X + int(math.Round(float64(to[i].X-from[i].X)*t)),
+			Y: from[i].Y + int(math.Round(float64(to[i].Y-from[i].Y)*t)),
+		}
+	}
+	return frame
+}
+
+// easeInOut eases the animation progress t, between 0 and 1, so clouds start
+// and stop moving smoothly.
+func easeInOut(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+// animateClouds moves the clouds from their current positions to target
+// over a short animation. Starting another animation cancels the running
+// one.
+func (mc *MovingClouds) animateClouds(ctx app.Context, target []position) {
+	from := mc.positions()
+	if len(from) != len(target) {
+		return
+	}
+
+	mc.animation++
+	animation := mc.animation
+
+	for f := 1; f <= arrangeFrames; f++ {
+		frame := interpolate(from, target, easeInOut(float64(f)/arrangeFrames))
+		ctx.After(time.Duration(f)*arrangeFrameInterval, func(ctx app.Context) {
+			if mc.animation != animation {
+				return
+			}
+			ctx.NewActionWithValue(moveAction, frame)
+		})
+	}
+}
+
+// arrangeClouds animates the clouds into a non-overlapping layout. The
+// previous layout is kept so it can be restored with undoArrange.
+func (mc *MovingClouds) arrangeClouds(ctx app.Context, e app.Event) {
+	w, h := app.Window().Size()
+	if w <= 0 {
+		w = defaultWidth
+	}
+	if h <= 0 {
+		h = defaultHeight
+	}
+
+	// Clouds that are locked or being dragged stay where they are, and the
+	// others are arranged around them.
+	current := mc.positions()
+	fixed := make([]bool, len(mc.clouds))
+	for i, c := range mc.clouds {
+		fixed[i] = c.locked || c.dragging
+	}
+
+	infof("arrange", "arranging %d clouds in a %dx%d window", len(current), w, h)
+	target := relaxLayout(current, fixed, w, h)
+	mc.recorder.addLayout("arrange", target)
+	mc.arrangeTo(ctx, target)
+}
+
+// arrangeTo animates the clouds to target, keeping their current layout so
+// it can be restored with undoArrange.
+func (mc *MovingClouds) arrangeTo(ctx app.Context, target []position) {
+	mc.undoLayouts = append(mc.undoLayouts, mc.positions())
+	mc.animateClouds(ctx, target)
+}
+
+// undoArrange animates the clouds back to the layout they had before the
+// last arrangement.
+func (mc *MovingClouds) undoArrange(ctx app.Context, e app.Event) {
+	n := len(mc.undoLayouts)
+	if n == 0 {
+		return
+	}
+
+	infof("arrange", "undoing arrangement")
+	previous := mc.undoLayouts[n-1]
+	mc.undoLayouts = mc.undoLayouts[:n-1]
+	mc.recorder.addLayout("undo", previous)
+	mc.animateClouds(ctx, previous)
+}
+
+// onMove moves the cloud to its position in a moveAction layout, unless it
+// is being dragged or is locked.
+func (b *draggableButton) onMove(ctx app.Context, a app.Action) {
+	layout, ok := a.Value.([]position)
+	if !ok || b.index >= len(layout) || b.dragging || b.locked {
+		return
+	}
+
+	b.left = layout[b.index].X
+	b.top = layout[b.index].Y
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestRelaxLayout(t *testing.T) {
+	tests := []struct {
+		name  string
+		start []position
+		fixed []bool
+		w, h  int
+
+		// crowded is set when the viewport is too small to hold the clouds
+		// apart, so they may overlap.
+		crowded bool
+	}{
+		{
+			name: "empty",
+			w:    800,
+			h:    600,
+		},
+		{
+			name:  "coincident clouds",
+			start: []position{{X: 300, Y: 200}, {X: 300, Y: 200}, {X: 300, Y: 200}, {X: 300, Y: 200}, {X: 300, Y: 200}},
+			fixed: make([]bool, 5),
+			w:     800,
+			h:     600,
+		},
+		{
+			name:  "scattered clouds",
+			start: []position{{X: 10, Y: 10}, {X: 40, Y: 30}, {X: 700, Y: 500}, {X: 650, Y: 480}, {X: 350, Y: 250}, {X: 360, Y: 260}},
+			fixed: make([]bool, 6),
+			w:     800,
+			h:     600,
+		},
+		{
+			name:  "clouds outside the viewport",
+			start: []position{{X: -400, Y: -300}, {X: 2000, Y: 1500}, {X: 300, Y: 5000}},
+			fixed: make([]bool, 3),
+			w:     800,
+			h:     600,
+		},
+		{
+			name:  "fixed clouds",
+			start: []position{{X: 350, Y: 250}, {X: 350, Y: 250}, {X: 360, Y: 240}, {X: 100, Y: 100}, {X: 120, Y: 110}},
+			fixed: []bool{true, false, false, true, false},
+			w:     800,
+			h:     600,
+		},
+		{
+			name:  "overlapping fixed clouds",
+			start: []position{{X: 100, Y: 100}, {X: 120, Y: 100}, {X: 110, Y: 110}},
+			fixed: []bool{true, true, false},
+			w:     800,
+			h:     600,
+		},
+		{
+			name:    "viewport smaller than a cloud",
+			start:   []position{{X: 0, Y: 0}, {X: 10, Y: 10}, {X: 20, Y: 5}},
+			fixed:   make([]bool, 3),
+			w:       cloudSize / 2,
+			h:       cloudSize / 2,
+			crowded: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := relaxLayout(test.start, test.fixed, test.w, test.h)
+			if len(got) != len(test.start) {
+				t.Fatalf("got %d positions, want %d", len(got), len(test.start))
+			}
+
+			for i, p := range got {
+				if test.fixed[i] {
+					if p != test.start[i] {
+						t.Errorf("fixed cloud %d moved from %+v to %+v", i, test.start[i], p)
+					}
+					continue
+				}
+				if p.X < 0 || p.Y < 0 || p.X > max(test.w-cloudSize, 0) || p.Y > max(test.h-cloudSize, 0) {
+					t.Errorf("cloud %d at %+v is out of the %dx%d viewport", i, p, test.w, test.h)
+				}
+			}
+
+			if test.crowded {
+				return
+			}
+			for i := range got {
+				for j := i + 1; j < len(got); j++ {
+					// Fixed clouds may overlap each other from the start.
+					if test.fixed[i] && test.fixed[j] {
+						continue
+					}
+					// Positions are rounded to the pixel.
+					if d := math.Hypot(float64(got[j].X-got[i].X), float64(got[j].Y-got[i].Y)); d < cloudSize-1 {
+						t.Errorf("clouds %d at %+v and %d at %+v overlap", i, got[i], j, got[j])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	from := []position{{X: 0, Y: 0}, {X: 100, Y: 50}}
+	to := []position{{X: 100, Y: -100}, {X: 100, Y: 51}}
+
+	tests := []struct {
+		t    float64
+		want []position
+	}{
+		{t: 0, want: from},
+		{t: 0.5, want: []position{{X: 50, Y: -50}, {X: 100, Y: 51}}},
+		{t: 1, want: to},
+	}
+
+	for _, test := range tests {
+		if got := interpolate(from, to, test.t); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("interpolate at %v = %+v, want %+v", test.t, got, test.want)
+		}
+	}
+}
+
+func TestEaseInOut(t *testing.T) {
+	if got := easeInOut(0); got != 0 {
+		t.Errorf("easeInOut(0) = %v, want 0", got)
+	}
+	if got := easeInOut(1); got != 1 {
+		t.Errorf("easeInOut(1) = %v, want 1", got)
+	}
+	if got := easeInOut(0.5); got != 0.5 {
+		t.Errorf("easeInOut(0.5) = %v, want 0.5", got)
+	}
+}
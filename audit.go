@@ -0,0 +1,439 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+)
+
+const (
+	// skyBackground is the image behind the sky, used to estimate the
+	// contrast of text drawn directly over it.
+	skyBackground = "/web/moving-clouds.png"
+
+	// auditAttr marks the audit overlay so it isn't audited itself.
+	auditAttr = "data-audit"
+
+	// interactiveSelector matches the elements users interact with.
+	interactiveSelector = "button, a[href], input, select, textarea, " +
+		"[role=button], [role=link], [role=menuitem], [role=checkbox], [tabindex]"
+)
+
+// auditIssue is an accessibility problem found on a rendered element.
+type auditIssue struct {
+	// Kind is "missing-label", "low-contrast" or "not-keyboard-reachable".
+	Kind string
+
+	// Element describes the element, e.g. button.cloud.
+	Element string
+
+	Detail string
+
+	// X, Y, W and H are the element bounds in client coordinates.
+	X int
+	Y int
+	W int
+	H int
+}
+
+func (i auditIssue) String() string {
+	return fmt.Sprintf("%s: %s at (%d, %d): %s", i.Kind, i.Element, i.X, i.Y, i.Detail)
+}
+
+// rgb is an opaque color.
+type rgb struct {
+	R, G, B float64
+}
+
+// parseCSSColor parses a computed CSS color such as "rgb(1, 2, 3)" or
+// "rgba(1, 2, 3, 0.5)". It returns the color and its opacity.
+func parseCSSColor(s string) (rgb, float64, bool) {
+	open, end := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	if open < 0 || end < open {
+		return rgb{}, 0, false
+	}
+
+	parts := strings.FieldsFunc(s[open+1:end], func(r rune) bool {
+		return r == ',' || r == ' ' || r == '/'
+	})
+	if len(parts) < 3 {
+		return rgb{}, 0, false
+	}
+
+	var v [4]float64
+	v[3] = 1
+	for i := 0; i < len(parts) && i < 4; i++ {
+		f, err := strconv.ParseFloat(strings.TrimSuffix(parts[i], "%"), 64)
+		if err != nil {
+			return rgb{}, 0, false
+		}
+		if strings.HasSuffix(parts[i], "%") {
+			f = f / 100
+			if i < 3 {
+				f *= 255
+			}
+		}
+		v[i] = f
+	}
+	return rgb{R: v[0], G: v[1], B: v[2]}, v[3], true
+}
+
+// luminance returns the WCAG relative luminance of c.
+func (c rgb) luminance() float64 {
+	channel := func(v float64) float64 {
+		v /= 255
+		if v <= 0.03928 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return 0.2126*channel(c.R) + 0.7152*channel(c.G) + 0.0722*channel(c.B)
+}
+
+// contrastRatio returns the WCAG contrast ratio between two colors, from 1
+// to 21.
+func contrastRatio(a, b rgb) float64 {
+	la, lb := a.luminance(), b.luminance()
+	return (math.Max(la, lb) + 0.05) / (math.Min(la, lb) + 0.05)
+}
+
+// blend returns c drawn with the given opacity over background.
+func blend(c rgb, alpha float64, background rgb) rgb {
+	return rgb{
+		R: c.R*alpha + background.R*(1-alpha),
+		G: c.G*alpha + background.G*(1-alpha),
+		B: c.B*alpha + background.B*(1-alpha),
+	}
+}
+
+// accessibilityAudit is the developer overlay shown with ?audit. It walks
+// the rendered page and highlights elements without an accessible name,
+// with text lacking contrast against what is behind it, or that can be
+// clicked but not reached with the keyboard.
+type accessibilityAudit struct {
+	app.Compo
+	issues  []auditIssue
+	skyTone rgb
+	ran     bool
+	copied  bool
+}
+
+func (a *accessibilityAudit) OnMount(ctx app.Context) {
+	// Until the sky image is loaded and averaged, its tone is estimated as a
+	// light sky blue.
+	a.skyTone = rgb{R: 135, G: 190, B: 235}
+	img := app.Window().Get("document").Call("createElement", "img")
+	var onLoad app.Func
+	onLoad = app.FuncOf(func(this app.Value, args []app.Value) any {
+		defer onLoad.Release()
+		tone := averageColor(img)
+		ctx.Dispatch(func(ctx app.Context) {
+			a.skyTone = tone
+			a.run(ctx)
+		})
+		return nil
+	})
+	img.Call("addEventListener", "load", onLoad)
+	img.Set("src", skyBackground)
+
+	ctx.Defer(func(ctx app.Context) {
+		a.run(ctx)
+		ctx.Update()
+	})
+}
+
+// averageColor returns the average color of a loaded image.
+func averageColor(img app.Value) rgb {
+	const size = 16
+
+	canvas := app.Window().Get("document").Call("createElement", "canvas")
+	canvas.Set("width", size)
+	canvas.Set("height", size)
+	c2d := canvas.Call("getContext", "2d")
+	c2d.Call("drawImage", img, 0, 0, size, size)
+
+	data := c2d.Call("getImageData", 0, 0, size, size).Get("data")
+	pixels := make([]byte, 4*size*size)
+	app.CopyBytesToGo(pixels, app.Window().Get("Uint8Array").New(data.Get("buffer")))
+
+	var sum rgb
+	for i := 0; i < len(pixels); i += 4 {
+		sum.R += float64(pixels[i])
+		sum.G += float64(pixels[i+1])
+		sum.B += float64(pixels[i+2])
+	}
+	n := float64(size * size)
+	return rgb{R: sum.R / n, G: sum.G / n, B: sum.B / n}
+}
+
+// run audits the page.
+func (a *accessibilityAudit) run(ctx app.Context) {
+	a.ran = true
+	a.copied = false
+	a.issues = nil
+
+	doc := app.Window().Get("document")
+	interactive := doc.Call("querySelectorAll", interactiveSelector)
+	for i := 0; i < interactive.Length(); i++ {
+		el := interactive.Index(i)
+		if skipAudit(el) {
+			continue
+		}
+		if accessibleName(doc, el) == "" {
+			a.add("missing-label", el, "has no text, aria-label, aria-labelledby, title or label")
+		}
+		if !keyboardReachable(el) {
+			a.add("not-keyboard-reachable", el, "can't be focused with the Tab key")
+		}
+	}
+
+	all := doc.Get("body").Call("querySelectorAll", "*")
+	for i := 0; i < all.Length(); i++ {
+		el := all.Index(i)
+		if skipAudit(el) || !hasOwnText(el) {
+			continue
+		}
+
+		style := app.Window().Call("getComputedStyle", el)
+		color, alpha, ok := parseCSSColor(style.Get("color").String())
+		if !ok {
+			continue
+		}
+		background := a.backgroundOf(el)
+		ratio := contrastRatio(blend(color, alpha, background), background)
+
+		minRatio := 4.5
+		size, _ := strconv.ParseFloat(strings.TrimSuffix(style.Get("fontSize").String(), "px"), 64)
+		weight, _ := strconv.Atoi(style.Get("fontWeight").String())
+		if size >= 24 || (size >= 18.66 && weight >= 700) {
+			minRatio = 3
+		}
+		if ratio < minRatio {
+			a.add("low-contrast", el, fmt.Sprintf("contrast ratio %.2f:1 is below %.1f:1", ratio, minRatio))
+		}
+	}
+
+	// Findings are for developers, so they are kept out of the warnings sent
+	// to the server.
+	for _, issue := range a.issues {
+		debugf("audit", "%s", issue)
+	}
+	infof("audit", "found %d accessibility issues", len(a.issues))
+}
+
+func (a *accessibilityAudit) add(kind string, el app.Value, detail string) {
+	rect := el.Call("getBoundingClientRect")
+	a.issues = append(a.issues, auditIssue{
+		Kind:    kind,
+		Element: describeElement(el),
+		Detail:  detail,
+		X:       rect.Get("left").Int(),
+		Y:       rect.Get("top").Int(),
+		W:       rect.Get("width").Int(),
+		H:       rect.Get("height").Int(),
+	})
+}
+
+// backgroundOf returns the color behind the text of el: the first opaque
+// background among its ancestors, or the sky tone when it is drawn over
+// an image.
+func (a *accessibilityAudit) backgroundOf(el app.Value) rgb {
+	var layers []rgb
+	var alphas []float64
+
+	background := rgb{R: 255, G: 255, B: 255}
+	for e := el; e.Truthy() && e.Get("nodeType").Int() == 1; e = e.Get("parentElement") {
+		style := app.Window().Call("getComputedStyle", e)
+		if style.Get("backgroundImage").String() != "none" {
+			background = a.skyTone
+			break
+		}
+
+		c, alpha, ok := parseCSSColor(style.Get("backgroundColor").String())
+		if !ok || alpha == 0 {
+			continue
+		}
+		if alpha >= 1 {
+			background = c
+			break
+		}
+		layers = append(layers, c)
+		alphas = append(alphas, alpha)
+	}
+
+	// Translucent backgrounds are blended from the farthest to the closest.
+	for i := len(layers) - 1; i >= 0; i-- {
+		background = blend(layers[i], alphas[i], background)
+	}
+	return background
+}
+
+// skipAudit reports whether el is part of the audit overlay or isn't
+// rendered.
+func skipAudit(el app.Value) bool {
+	if el.Call("closest", "["+auditAttr+"]").Truthy() {
+		return true
+	}
+	return el.Call("getClientRects").Length() == 0
+}
+
+// accessibleName approximates the accessible name of el.
+func accessibleName(doc, el app.Value) string {
+	for _, attr := range []string{"aria-label", "title", "alt"} {
+		if v := el.Call("getAttribute", attr); v.Truthy() && strings.TrimSpace(v.String()) != "" {
+			return v.String()
+		}
+	}
+
+	if ids := el.Call("getAttribute", "aria-labelledby"); ids.Truthy() {
+		var names []string
+		for _, id := range strings.Fields(ids.String()) {
+			if label := doc.Call("getElementById", id); label.Truthy() {
+				names = append(names, strings.TrimSpace(label.Get("textContent").String()))
+			}
+		}
+		if name := strings.Join(names, " "); strings.TrimSpace(name) != "" {
+			return name
+		}
+	}
+
+	if labels := el.Get("labels"); labels.Truthy() && labels.Length() != 0 {
+		return strings.TrimSpace(labels.Index(0).Get("textContent").String())
+	}
+
+	switch el.Get("tagName").String() {
+	case "INPUT", "SELECT", "TEXTAREA":
+		return ""
+	}
+	return strings.TrimSpace(el.Get("textContent").String())
+}
+
+// keyboardReachable reports whether el can be focused with the Tab key.
+// Disabled controls aren't expected to be.
+func keyboardReachable(el app.Value) bool {
+	if el.Get("disabled").Truthy() {
+		return true
+	}
+	return el.Get("tabIndex").Int() >= 0
+}
+
+// hasOwnText reports whether el directly contains visible text.
+func hasOwnText(el app.Value) bool {
+	nodes := el.Get("childNodes")
+	for i := 0; i < nodes.Length(); i++ {
+		n := nodes.Index(i)
+		if n.Get("nodeType").Int() == 3 && strings.TrimSpace(n.Get("textContent").String()) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// describeElement returns a short CSS-like description of el.
+func describeElement(el app.Value) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(el.Get("tagName").String()))
+	if id := el.Get("id").String(); id != "" {
+		b.WriteString("#" + id)
+	}
+	if class := el.Call("getAttribute", "class"); class.Truthy() {
+		for _, c := range strings.Fields(class.String()) {
+			b.WriteString("." + c)
+		}
+	}
+	if text := strings.TrimSpace(el.Get("textContent").String()); text != "" {
+		if r := []rune(text); len(r) > 24 {
+			text = string(r[:24]) + "…"
+		}
+		b.WriteString(fmt.Sprintf(" %q", text))
+	}
+	return b.String()
+}
+
+func (a *accessibilityAudit) report() string {
+	lines := make([]string, len(a.issues))
+	for i, issue := range a.issues {
+		lines[i] = fmt.Sprintf("%d. %s", i+1, issue)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (a *accessibilityAudit) copyReport(ctx app.Context, e app.Event) {
+	app.Window().Get("navigator").Get("clipboard").Call("writeText", a.report())
+	a.copied = true
+}
+
+func (a *accessibilityAudit) Render() app.UI {
+	copyLabel := "Copy report"
+	if a.copied {
+		copyLabel = "Copied"
+	}
+
+	return app.Div().
+		Attr(auditAttr, true).
+		Body(
+			app.Range(a.issues).Slice(func(i int) app.UI {
+				issue := a.issues[i]
+				return app.Div().
+					Title(issue.String()).
+					Style("position", "fixed").
+					Style("left", strconv.Itoa(issue.X)+"px").
+					Style("top", strconv.Itoa(issue.Y)+"px").
+					Style("width", strconv.Itoa(issue.W)+"px").
+					Style("height", strconv.Itoa(issue.H)+"px").
+					Style("outline", "2px dashed crimson").
+					Style("pointer-events", "none").
+					Body(
+						app.Span().
+							Style("position", "absolute").
+							Style("top", "-10px").
+							Style("left", "-10px").
+							Style("padding", "0 4px").
+							Style("font", "bold 12px sans-serif").
+							Style("color", "white").
+							Style("background-color", "crimson").
+							Text(i + 1),
+					)
+			}),
+			app.Aside().
+				Aria("label", "Accessibility audit").
+				Style("position", "fixed").
+				Style("top", "48px").
+				Style("right", "8px").
+				Style("width", "320px").
+				Style("max-height", "60vh").
+				Style("overflow", "auto").
+				Style("padding", "8px").
+				Style("font", "12px monospace").
+				Style("background-color", "white").
+				Style("box-shadow", "0 2px 6px rgba(0, 0, 0, 0.3)").
+				Body(
+					app.H2().
+						Style("margin", "0 0 8px").
+						Style("font-size", "14px").
+						Text("Accessibility audit"),
+					app.Div().Body(
+						app.Button().
+							Text("Re-run").
+							OnClick(func(ctx app.Context, e app.Event) {
+								a.run(ctx)
+							}),
+						app.Button().
+							Disabled(len(a.issues) == 0).
+							Text(copyLabel).
+							OnClick(a.copyReport),
+					),
+					app.If(a.ran && len(a.issues) == 0, func() app.UI {
+						return app.P().Text("No issues found.")
+					}),
+					app.Ol().Body(
+						app.Range(a.issues).Slice(func(i int) app.UI {
+							return app.Li().Text(a.issues[i].String())
+						}),
+					),
+				),
+		)
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+)
+
+const (
+	// logBufferSize is the number of log entries kept by the client.
+	logBufferSize = 200
+
+	// logStorageKey is the session storage key where the client log is kept,
+	// so the debug console can show logs from before it was opened.
+	logStorageKey = "/movingclouds/log"
+
+	// clientErrorsEnv is the environment variable passed to the client with
+	// the URL warnings and errors are sent to. Logs aren't sent when it is
+	// empty.
+	clientErrorsEnv = "CLIENT_ERRORS_URL"
+
+	// clientErrorsPath is where the server receives client warnings and
+	// errors, when enabled.
+	clientErrorsPath = "/client-errors"
+
+	// maxClientErrorSize is the maximum size of a log entry sent by a client.
+	maxClientErrorSize = 16 << 10
+
+	// clientErrorsLimit is the number of log entries a remote address can
+	// send per clientErrorsWindow. Entries over the limit are dropped.
+	clientErrorsLimit  = 30
+	clientErrorsWindow = time.Minute
+)
+
+// logLevel is the severity of a log entry.
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+func (l logLevel) String() string {
+	switch l {
+	case levelDebug:
+		return "debug"
+	case levelInfo:
+		return "info"
+	case levelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// logEntry is a single client log message.
+type logEntry struct {
+	Time    time.Time `json:"time"`
+	Level   logLevel  `json:"level"`
+	Tag     string    `json:"tag"`
+	Message string    `json:"message"`
+}
+
+func (e logEntry) String() string {
+	return fmt.Sprintf("%s [%s] %s: %s", e.Time.Format(time.RFC3339), e.Level, e.Tag, e.Message)
+}
+
+// logBuffer is a ring buffer of the most recent client log entries.
+type logBuffer struct {
+	mu      sync.Mutex
+	loaded  bool
+	entries []logEntry
+}
+
+// clientLog is the client log. It is filled with debugf, infof, warnf and
+// errorf.
+var clientLog logBuffer
+
+// load restores the entries saved in session storage by a previous page of
+// the same tab.
+func (b *logBuffer) load() {
+	if b.loaded || !app.IsClient {
+		return
+	}
+	b.loaded = true
+
+	data := app.Window().Get("sessionStorage").Call("getItem", logStorageKey)
+	if !data.Truthy() {
+		return
+	}
+
+	var entries []logEntry
+	if err := json.Unmarshal([]byte(data.String()), &entries); err != nil {
+		return
+	}
+	b.entries = append(entries, b.entries...)
+	b.trim()
+}
+
+// save writes the entries to session storage.
+func (b *logBuffer) save() {
+	if !app.IsClient {
+		return
+	}
+
+	data, err := json.Marshal(b.entries)
+	if err != nil {
+		return
+	}
+	app.Window().Get("sessionStorage").Call("setItem", logStorageKey, string(data))
+}
+
+func (b *logBuffer) trim() {
+	if n := len(b.entries); n > logBufferSize {
+		b.entries = b.entries[n-logBufferSize:]
+	}
+}
+
+// add records an entry.
+func (b *logBuffer) add(e logEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.load()
+	b.entries = append(b.entries, e)
+	b.trim()
+	b.save()
+}
+
+// recent returns a copy of the recorded entries, oldest first.
+func (b *logBuffer) recent() []logEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.load()
+	return append([]logEntry(nil), b.entries...)
+}
+
+// clear removes every entry.
+func (b *logBuffer) clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.loaded = true
+	b.entries = nil
+	b.save()
+}
+
+// logf records a message in the client log, prints it to the browser
+// console and, for warnings and errors, sends it to the server when enabled.
+func logf(level logLevel, tag, format string, v ...any) {
+	e := logEntry{
+		Time:    time.Now(),
+		Level:   level,
+		Tag:     tag,
+		Message: fmt.Sprintf(format, v...),
+	}
+
+	clientLog.add(e)
+	app.Log(e.String())
+
+	if level >= levelWarn {
+		sendLogEntry(e)
+	}
+}
+
+func debugf(tag, format string, v ...any) { logf(levelDebug, tag, format, v...) }
+func infof(tag, format string, v ...any)  { logf(levelInfo, tag, format, v...) }
+func warnf(tag, format string, v ...any)  { logf(levelWarn, tag, format, v...) }
+func errorf(tag, format string, v ...any) { logf(levelError, tag, format, v...) }
+
+// sendLogEntry posts a log entry to the server error endpoint, if the server
+// enabled it. Failing to send is silently ignored.
+func sendLogEntry(e logEntry) {
+	url := app.Getenv(clientErrorsEnv)
+	if url == "" || !app.IsClient {
+		return
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	app.Window().Call("fetch", url, map[string]any{
+		"method":    "POST",
+		"body":      string(data),
+		"keepalive": true,
+		"headers":   map[string]any{"Content-Type": "application/json"},
+	})
+}
+
+// rateLimiter counts requests per key over fixed time windows.
+type rateLimiter struct {
+	Limit  int
+	Window time.Duration
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+// allow counts a request for key and reports whether it is within the
+// limit of the current window.
+func (l *rateLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Counts are reset at every window so they don't grow with the number of
+	// keys ever seen.
+	if now := time.Now(); l.counts == nil || now.Sub(l.start) >= l.Window {
+		l.start = now
+		l.counts = make(map[string]int)
+	}
+
+	l.counts[key]++
+	return l.counts[key] <= l.Limit
+}
+
+// clientErrorsLimiter limits how many log entries each remote address can
+// write to the server log.
+var clientErrorsLimiter = rateLimiter{
+	Limit:  clientErrorsLimit,
+	Window: clientErrorsWindow,
+}
+
+// clientErrors receives the warnings and errors sent by clients and writes
+// them to the server log.
+func clientErrors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !clientErrorsLimiter.allow(host) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(clientErrorsWindow.Seconds())))
+		http.Error(w, "too many log entries", http.StatusTooManyRequests)
+		return
+	}
+
+	var e logEntry
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxClientErrorSize)).Decode(&e); err != nil {
+		http.Error(w, "invalid log entry", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("client %s [%s] %q: %q", r.RemoteAddr, e.Level, e.Tag, e.Message)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// debugConsole is the hidden page, at /debug, that shows the recent client
+// logs so users can copy them into bug reports.
+type debugConsole struct {
+	app.Compo
+	entries []logEntry
+	copied  bool
+}
+
+func (c *debugConsole) OnMount(ctx app.Context) {
+	c.entries = clientLog.recent()
+}
+
+func (c *debugConsole) copyLogs(ctx app.Context, e app.Event) {
+	lines := make([]string, len(c.entries))
+	for i, e := range c.entries {
+		lines[i] = e.String()
+	}
+	app.Window().Get("navigator").Get("clipboard").Call("writeText", strings.Join(lines, "\n"))
+	c.copied = true
+}
+
+func (c *debugConsole) clearLogs(ctx app.Context, e app.Event) {
+	clientLog.clear()
+	c.entries = nil
+	c.copied = false
+}
+
+func (c *debugConsole) Render() app.UI {
+	copyLabel := "Copy logs"
+	if c.copied {
+		copyLabel = "Copied"
+	}
+
+	return app.Main().
+		Style("font-family", "monospace").
+		Style("padding", "16px").
+		Body(
+			app.H1().Text("Debug console"),
+			app.P().Text("Recent logs of this tab, oldest first. Copy them into your bug report."),
+			app.Div().Body(
+				app.Button().
+					Text(copyLabel).
+					OnClick(c.copyLogs),
+				app.Button().
+					Text("Clear").
+					OnClick(c.clearLogs),
+				app.A().
+					Href("/").
+					Style("margin-left", "8px").
+					Text("Back to the sky"),
+			),
+			app.If(len(c.entries) == 0, func() app.UI {
+				return app.P().Text("No logs yet.")
+			}).Else(func() app.UI {
+				return app.Pre().
+					Style("white-space", "pre-wrap").
+					Body(
+						app.Range(c.entries).Slice(func(i int) app.UI {
+							color := "inherit"
+							switch c.entries[i].Level {
+							case levelWarn:
+								color = "darkorange"
+							case levelError:
+								color = "crimson"
+							}
+							return app.Div().
+								Style("color", color).
+								Text(c.entries[i].String())
+						}),
+					)
+			}),
+		)
+}
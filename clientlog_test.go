@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestClientErrors(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	const entry = `{"level":3,"tag":"test","message":"boom"}`
+
+	tests := []struct {
+		name string
+
+		// before is the number of valid entries sent from the same
+		// address before the tested request.
+		before int
+
+		method         string
+		body           string
+		wantStatus     int
+		wantAllow      string
+		wantRetryAfter string
+	}{
+		{
+			name:       "valid entry",
+			method:     http.MethodPost,
+			body:       entry,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "not a post",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  http.MethodPost,
+		},
+		{
+			name:       "invalid entry",
+			method:     http.MethodPost,
+			body:       "not json",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "entry too large",
+			method:     http.MethodPost,
+			body:       `{"message":"` + strings.Repeat("x", maxClientErrorSize) + `"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "last entry within the limit",
+			before:     clientErrorsLimit - 1,
+			method:     http.MethodPost,
+			body:       entry,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:           "entry over the limit",
+			before:         clientErrorsLimit,
+			method:         http.MethodPost,
+			body:           entry,
+			wantStatus:     http.StatusTooManyRequests,
+			wantRetryAfter: strconv.Itoa(int(clientErrorsWindow.Seconds())),
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Every test uses its own address so limits don't carry over.
+			remoteAddr := "192.0.2." + strconv.Itoa(i+1) + ":1234"
+			post := func(body string) *httptest.ResponseRecorder {
+				r := httptest.NewRequest(test.method, clientErrorsPath, strings.NewReader(body))
+				r.RemoteAddr = remoteAddr
+				w := httptest.NewRecorder()
+				clientErrors(w, r)
+				return w
+			}
+
+			for range test.before {
+				if w := post(entry); w.Code != http.StatusNoContent {
+					t.Fatalf("entry before the test got status %d", w.Code)
+				}
+			}
+
+			w := post(test.body)
+			if w.Code != test.wantStatus {
+				t.Fatalf("status is %d, want %d", w.Code, test.wantStatus)
+			}
+			if got := w.Header().Get("Allow"); got != test.wantAllow {
+				t.Errorf("Allow is %q, want %q", got, test.wantAllow)
+			}
+			if got := w.Header().Get("Retry-After"); got != test.wantRetryAfter {
+				t.Errorf("Retry-After is %q, want %q", got, test.wantRetryAfter)
+			}
+		})
+	}
+}
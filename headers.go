@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	// defaultContentSecurityPolicy allows what go-app needs to run: its own
+	// scripts, compiling app.wasm, the service worker, inline styles set by
+	// components, and the default go-app icons hosted on GitHub. The service
+	// worker fetches and caches those icons too, so their origin is also
+	// allowed to be connected to.
+	defaultContentSecurityPolicy = "default-src 'self'; " +
+		"script-src 'self' 'wasm-unsafe-eval'; " +
+		"style-src 'self' 'unsafe-inline'; " +
+		"img-src 'self' data: blob: https://raw.githubusercontent.com; " +
+		"worker-src 'self'; " +
+		"manifest-src 'self'; " +
+		"connect-src 'self' https://raw.githubusercontent.com; " +
+		"object-src 'none'; " +
+		"base-uri 'self'"
+
+	defaultFrameOptions      = "DENY"
+	defaultReferrerPolicy    = "strict-origin-when-cross-origin"
+	defaultPermissionsPolicy = "camera=(), microphone=(), geolocation=(), payment=(), usb=()"
+)
+
+// securityHeaders are the security related headers set on every response.
+// An empty value leaves the header unset.
+type securityHeaders struct {
+	ContentSecurityPolicy string
+	FrameOptions          string
+	ReferrerPolicy        string
+	PermissionsPolicy     string
+}
+
+// wrap returns a handler that sets the security headers before calling h.
+func (s securityHeaders) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		setHeader(header, "Content-Security-Policy", s.contentSecurityPolicy())
+		setHeader(header, "X-Frame-Options", s.FrameOptions)
+		setHeader(header, "Referrer-Policy", s.ReferrerPolicy)
+		setHeader(header, "Permissions-Policy", s.PermissionsPolicy)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// contentSecurityPolicy returns the Content-Security-Policy header value.
+// Browsers ignore X-Frame-Options when the policy has a frame-ancestors
+// directive, so one matching FrameOptions is added unless the policy sets its
+// own.
+func (s securityHeaders) contentSecurityPolicy() string {
+	csp := strings.TrimRight(s.ContentSecurityPolicy, "; ")
+	if csp == "" || strings.Contains(csp, "frame-ancestors") {
+		return csp
+	}
+
+	switch strings.ToUpper(s.FrameOptions) {
+	case "DENY":
+		return csp + "; frame-ancestors 'none'"
+	case "SAMEORIGIN":
+		return csp + "; frame-ancestors 'self'"
+	default:
+		return csp
+	}
+}
+
+// setHeader sets the header k to v, unless v is empty.
+func setHeader(header http.Header, k, v string) {
+	if v != "" {
+		header.Set(k, v)
+	}
+}
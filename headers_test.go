@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	defaults := securityHeaders{
+		ContentSecurityPolicy: defaultContentSecurityPolicy,
+		FrameOptions:          defaultFrameOptions,
+		ReferrerPolicy:        defaultReferrerPolicy,
+		PermissionsPolicy:     defaultPermissionsPolicy,
+	}
+
+	noFrameOptions := defaults
+	noFrameOptions.FrameOptions = ""
+
+	sameOrigin := defaults
+	sameOrigin.FrameOptions = "SAMEORIGIN"
+
+	customFraming := sameOrigin
+	customFraming.ContentSecurityPolicy = "default-src 'self'; frame-ancestors https://example.com;"
+
+	tests := []struct {
+		name    string
+		headers securityHeaders
+		want    map[string]string
+	}{
+		{
+			name:    "defaults",
+			headers: defaults,
+			want: map[string]string{
+				"Content-Security-Policy": defaultContentSecurityPolicy + "; frame-ancestors 'none'",
+				"X-Frame-Options":         defaultFrameOptions,
+				"Referrer-Policy":         defaultReferrerPolicy,
+				"Permissions-Policy":      defaultPermissionsPolicy,
+			},
+		},
+		{
+			name:    "empty value omits the header",
+			headers: noFrameOptions,
+			want: map[string]string{
+				"Content-Security-Policy": defaultContentSecurityPolicy,
+				"Referrer-Policy":         defaultReferrerPolicy,
+				"Permissions-Policy":      defaultPermissionsPolicy,
+			},
+		},
+		{
+			name:    "same origin framing",
+			headers: sameOrigin,
+			want: map[string]string{
+				"Content-Security-Policy": defaultContentSecurityPolicy + "; frame-ancestors 'self'",
+				"X-Frame-Options":         "SAMEORIGIN",
+				"Referrer-Policy":         defaultReferrerPolicy,
+				"Permissions-Policy":      defaultPermissionsPolicy,
+			},
+		},
+		{
+			name:    "policy with its own framing",
+			headers: customFraming,
+			want: map[string]string{
+				"Content-Security-Policy": "default-src 'self'; frame-ancestors https://example.com",
+				"X-Frame-Options":         "SAMEORIGIN",
+				"Referrer-Policy":         defaultReferrerPolicy,
+				"Permissions-Policy":      defaultPermissionsPolicy,
+			},
+		},
+		{
+			name: "all empty",
+			want: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := test.headers.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			for _, k := range []string{"Content-Security-Policy", "X-Frame-Options", "Referrer-Policy", "Permissions-Policy"} {
+				got, set := w.Header()[k]
+				want, wantSet := test.want[k]
+				switch {
+				case !wantSet && set:
+					t.Errorf("%s is set to %q, want it unset", k, got)
+				case wantSet && w.Header().Get(k) != want:
+					t.Errorf("%s is %q, want %q", k, w.Header().Get(k), want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"math"
+	"sync"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+)
+
+const (
+	// pressAction and menuAction are posted by the sky to the cloud that was
+	// hit by a primary button press or a right-click. Their value is a
+	// cloudHit.
+	pressAction = "cloud/press"
+	menuAction  = "cloud/menu"
+
+	// skyID is the ID of the sky element, which clouds are positioned in.
+	skyID = "sky"
+
+	// alphaThreshold is the minimum opacity, out of 255, of a sprite pixel for
+	// the pointer to hit it.
+	alphaThreshold = 32
+)
+
+// cloudHit is a pointer event resolved to the cloud it targets.
+type cloudHit struct {
+	Cloud int
+	X     int
+	Y     int
+}
+
+// spriteMask is the opacity of a sprite as rendered on a cloud, one byte per
+// pixel of a cloudSize square.
+type spriteMask []byte
+
+// opaqueAt reports whether the pixel at x, y of a cloud is opaque enough to
+// be hit.
+func (m spriteMask) opaqueAt(x, y int) bool {
+	if x < 0 || y < 0 || x >= cloudSize || y >= cloudSize {
+		return false
+	}
+	return m[y*cloudSize+x] >= alphaThreshold
+}
+
+// spriteMasks caches the masks of the sprites loaded with loadSpriteMask.
+var spriteMasks = struct {
+	sync.Mutex
+	masks   map[string]spriteMask
+	loading map[string]bool
+}{
+	masks:   make(map[string]spriteMask),
+	loading: make(map[string]bool),
+}
+
+// lookupSpriteMask returns the mask of a loaded sprite.
+func lookupSpriteMask(image string) (spriteMask, bool) {
+	spriteMasks.Lock()
+	defer spriteMasks.Unlock()
+
+	m, ok := spriteMasks.masks[image]
+	return m, ok
+}
+
+// loadSpriteMask loads a sprite in the background and computes its mask.
+// Until the mask is loaded, the whole cloud area can be hit.
+func loadSpriteMask(image string) {
+	if !app.IsClient {
+		return
+	}
+
+	spriteMasks.Lock()
+	defer spriteMasks.Unlock()
+	if spriteMasks.loading[image] {
+		return
+	}
+	spriteMasks.loading[image] = true
+
+	img := app.Window().Get("document").Call("createElement", "img")
+	var onLoad, onError app.Func
+	release := func() {
+		onLoad.Release()
+		onError.Release()
+	}
+
+	onLoad = app.FuncOf(func(this app.Value, args []app.Value) any {
+		defer release()
+		mask := rasterizeMask(img)
+
+		spriteMasks.Lock()
+		spriteMasks.masks[image] = mask
+		spriteMasks.Unlock()
+		return nil
+	})
+	onError = app.FuncOf(func(this app.Value, args []app.Value) any {
+		defer release()
+		warnf("hittest", "loading sprite %q failed: its whole area will be hit", image)
+		return nil
+	})
+
+	img.Call("addEventListener", "load", onLoad)
+	img.Call("addEventListener", "error", onError)
+	img.Set("src", image)
+}
+
+// rasterizeMask draws a loaded image the way a cloud shows it, scaled to
+// cover a cloudSize square and centered, then extracts its opacity.
+func rasterizeMask(img app.Value) spriteMask {
+	w := img.Get("naturalWidth").Float()
+	h := img.Get("naturalHeight").Float()
+	scale := math.Max(cloudSize/w, cloudSize/h)
+	cropW, cropH := cloudSize/scale, cloudSize/scale
+
+	canvas := app.Window().Get("document").Call("createElement", "canvas")
+	canvas.Set("width", cloudSize)
+	canvas.Set("height", cloudSize)
+	c2d := canvas.Call("getContext", "2d")
+	c2d.Call("drawImage", img, (w-cropW)/2, (h-cropH)/2, cropW, cropH, 0, 0, cloudSize, cloudSize)
+
+	data := c2d.Call("getImageData", 0, 0, cloudSize, cloudSize).Get("data")
+	rgba := make([]byte, 4*cloudSize*cloudSize)
+	app.CopyBytesToGo(rgba, app.Window().Get("Uint8Array").New(data.Get("buffer")))
+
+	mask := make(spriteMask, cloudSize*cloudSize)
+	for i := range mask {
+		mask[i] = rgba[4*i+3]
+	}
+	return mask
+}
+
+// hitTest returns the index of the top-most cloud under p, in sky
+// coordinates, or -1 when there is none. Clouds later in the slice are drawn
+// on top. The pointer goes through the transparent parts of a sprite to the
+// clouds below, and through locked clouds when skipLocked is set.
+func hitTest(clouds []*draggableButton, p position, skipLocked bool) int {
+	for i := len(clouds) - 1; i >= 0; i-- {
+		c := clouds[i]
+		if skipLocked && c.locked {
+			continue
+		}
+
+		x, y := p.X-c.left, p.Y-c.top
+		if x < 0 || y < 0 || x >= cloudSize || y >= cloudSize {
+			continue
+		}
+		if mask, ok := lookupSpriteMask(c.Image); ok && !mask.opaqueAt(x, y) {
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+// skyPosition converts client coordinates to coordinates within the sky,
+// where clouds are positioned. They differ by the page margin and by how far
+// the page is scrolled.
+func skyPosition(clientX, clientY int) position {
+	sky := app.Window().GetElementByID(skyID)
+	if !sky.Truthy() {
+		return position{X: clientX, Y: clientY}
+	}
+
+	rect := sky.Call("getBoundingClientRect")
+	return position{
+		X: clientX - rect.Get("left").Int(),
+		Y: clientY - rect.Get("top").Int(),
+	}
+}
+
+// eventSkyPosition returns the position of a mouse event within the sky.
+func eventSkyPosition(e app.Event) position {
+	p := eventPosition(e)
+	return skyPosition(p.X, p.Y)
+}
+
+// isDirectTarget reports whether an event was fired on the element handling
+// it rather than on one of its children.
+func isDirectTarget(e app.Event) bool {
+	ev := e.JSValue()
+	return ev.Get("target").Equal(ev.Get("currentTarget"))
+}
+
+// onPointerDown starts dragging the unlocked cloud under the pointer.
+func (mc *MovingClouds) onPointerDown(ctx app.Context, e app.Event) {
+	ctx.PreventUpdate()
+	if !isDirectTarget(e) || e.JSValue().Get("button").Int() != 0 {
+		return
+	}
+
+	p := eventSkyPosition(e)
+	if i := hitTest(mc.clouds, p, true); i >= 0 {
+		// Prevents the browser from selecting text while dragging.
+		e.PreventDefault()
+		ctx.NewActionWithValue(pressAction, cloudHit{Cloud: i, X: p.X, Y: p.Y})
+	}
+}
+
+// onPointerOver shows a move cursor when the pointer is over a cloud that
+// can be dragged.
+func (mc *MovingClouds) onPointerOver(ctx app.Context, e app.Event) {
+	hovering := isDirectTarget(e) && hitTest(mc.clouds, eventSkyPosition(e), true) >= 0
+	if hovering == mc.hovering {
+		ctx.PreventUpdate()
+		return
+	}
+	mc.hovering = hovering
+}
+
+// onSkyContextMenu opens the menu of the cloud under the pointer. Locked
+// clouds are hit too, so they can be unlocked.
+func (mc *MovingClouds) onSkyContextMenu(ctx app.Context, e app.Event) {
+	ctx.PreventUpdate()
+	if !isDirectTarget(e) {
+		return
+	}
+
+	p := eventSkyPosition(e)
+	if i := hitTest(mc.clouds, p, false); i >= 0 {
+		e.PreventDefault()
+		ctx.NewActionWithValue(menuAction, cloudHit{Cloud: i, X: p.X, Y: p.Y})
+	}
+}
+
+// onPress starts dragging the cloud when the sky resolved a press to it.
+func (b *draggableButton) onPress(ctx app.Context, a app.Action) {
+	hit, ok := a.Value.(cloudHit)
+	if !ok || hit.Cloud != b.index || b.locked {
+		return
+	}
+	b.startDrag(ctx, hit.X, hit.Y)
+}
+
+// onMenu opens the cloud menu when the sky resolved a right-click to it.
+func (b *draggableButton) onMenu(ctx app.Context, a app.Action) {
+	hit, ok := a.Value.(cloudHit)
+	if !ok || hit.Cloud != b.index {
+		return
+	}
+	b.showMenu(ctx, hit.X, hit.Y)
+}
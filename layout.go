@@ -0,0 +1,166 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+)
+
+const (
+	// cloudSize is the width and height of a cloud sprite, in pixels.
+	cloudSize = 100
+
+	// cloudImage is the sprite used for clouds.
+	cloudImage = "/web/cloud.png"
+
+	// defaultClouds is the number of clouds shown when the URL doesn't ask
+	// for a specific count.
+	defaultClouds = 4
+
+	// defaultMaxClouds is the default maximum number of clouds in a sky.
+	defaultMaxClouds = 50
+
+	// maxCloudsEnv is the environment variable passing the maximum number of
+	// clouds from the server to the client.
+	maxCloudsEnv = "MAX_CLOUDS"
+
+	// defaultWidth and defaultHeight are used when the viewport size is
+	// unknown, which is always the case during server-side prerendering.
+	defaultWidth  = 800
+	defaultHeight = 600
+)
+
+// maxClouds is the maximum number of clouds in a sky, so neither a link nor
+// adding clouds can make the page render an unbounded number of elements. It
+// is set with the -max-clouds flag on the server, which passes it to the
+// client.
+var maxClouds = defaultMaxClouds
+
+// loadMaxClouds reads the maximum number of clouds passed by the server.
+func loadMaxClouds() {
+	if n, err := strconv.Atoi(app.Getenv(maxCloudsEnv)); err == nil && n >= 0 {
+		maxClouds = n
+	}
+}
+
+// position is the top-left corner of a cloud, in pixels.
+type position struct {
+	X int
+	Y int
+}
+
+// skyConfig describes the initial sky requested by the page URL.
+//
+// It is read from the following query parameters:
+//   - clouds: the number of clouds, e.g. ?clouds=5.
+//   - layout: how the clouds are placed: "scattered" (default), "row" or
+//     "grid".
+//   - at: explicit coordinates, one cloud per parameter, e.g.
+//     ?at=120,80&at=300,200. When present, it defines both the number of
+//     clouds and where they are, and the other parameters are ignored.
+type skyConfig struct {
+	Clouds int
+	Layout string
+	At     []position
+}
+
+// parseSkyConfig reads the sky configuration from URL query values. Invalid
+// or missing values fall back to the defaults.
+func parseSkyConfig(q url.Values) skyConfig {
+	cfg := skyConfig{
+		Clouds: min(defaultClouds, maxClouds),
+		Layout: q.Get("layout"),
+	}
+
+	if n, err := strconv.Atoi(q.Get("clouds")); err == nil && n >= 0 {
+		cfg.Clouds = min(n, maxClouds)
+	}
+
+	for _, pair := range q["at"] {
+		if len(cfg.At) >= maxClouds {
+			break
+		}
+
+		x, y, ok := strings.Cut(pair, ",")
+		if !ok {
+			continue
+		}
+		left, errX := strconv.Atoi(strings.TrimSpace(x))
+		top, errY := strconv.Atoi(strings.TrimSpace(y))
+		if errX != nil || errY != nil {
+			continue
+		}
+		cfg.At = append(cfg.At, position{X: left, Y: top})
+	}
+	return cfg
+}
+
+// positions returns where each cloud starts within a w by h viewport.
+func (c skyConfig) positions(w, h int) []position {
+	if len(c.At) != 0 {
+		return c.At
+	}
+
+	switch c.Layout {
+	case "row":
+		return rowLayout(c.Clouds, w, h)
+
+	case "grid":
+		return gridLayout(c.Clouds, w, h)
+
+	default:
+		return scatteredLayout(c.Clouds, w, h)
+	}
+}
+
+// scatteredLayout places n clouds at random within the viewport.
+func scatteredLayout(n, w, h int) []position {
+	positions := make([]position, n)
+	for i := range positions {
+		positions[i] = position{
+			X: rand.Intn(max(w-cloudSize, 1)),
+			Y: rand.Intn(max(h-cloudSize, 1)),
+		}
+	}
+	return positions
+}
+
+// rowLayout places n clouds evenly along a horizontal line across the
+// middle of the viewport.
+func rowLayout(n, w, h int) []position {
+	positions := make([]position, n)
+	step := float64(w) / float64(n+1)
+	for i := range positions {
+		positions[i] = position{
+			X: int(step*float64(i+1)) - cloudSize/2,
+			Y: (h - cloudSize) / 2,
+		}
+	}
+	return positions
+}
+
+// gridLayout places n clouds in evenly spaced rows and columns, filling the
+// viewport.
+func gridLayout(n, w, h int) []position {
+	if n == 0 {
+		return nil
+	}
+
+	cols := int(math.Ceil(math.Sqrt(float64(n))))
+	rows := (n + cols - 1) / cols
+	stepX := float64(w) / float64(cols+1)
+	stepY := float64(h) / float64(rows+1)
+
+	positions := make([]position, n)
+	for i := range positions {
+		positions[i] = position{
+			X: int(stepX*float64(i%cols+1)) - cloudSize/2,
+			Y: int(stepY*float64(i/cols+1)) - cloudSize/2,
+		}
+	}
+	return positions
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// setMaxClouds sets the cloud limit for the duration of a test.
+func setMaxClouds(t *testing.T, n int) {
+	previous := maxClouds
+	maxClouds = n
+	t.Cleanup(func() { maxClouds = previous })
+}
+
+func TestParseSkyConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		maxClouds int
+		want      skyConfig
+	}{
+		{
+			name:      "defaults",
+			query:     "",
+			maxClouds: defaultMaxClouds,
+			want:      skyConfig{Clouds: defaultClouds},
+		},
+		{
+			name:      "clouds and layout",
+			query:     "clouds=5&layout=grid",
+			maxClouds: defaultMaxClouds,
+			want:      skyConfig{Clouds: 5, Layout: "grid"},
+		},
+		{
+			name:      "no clouds",
+			query:     "clouds=0",
+			maxClouds: defaultMaxClouds,
+			want:      skyConfig{Clouds: 0},
+		},
+		{
+			name:      "negative clouds",
+			query:     "clouds=-1",
+			maxClouds: defaultMaxClouds,
+			want:      skyConfig{Clouds: defaultClouds},
+		},
+		{
+			name:      "invalid clouds",
+			query:     "clouds=many",
+			maxClouds: defaultMaxClouds,
+			want:      skyConfig{Clouds: defaultClouds},
+		},
+		{
+			name:      "clouds over the limit",
+			query:     "clouds=500",
+			maxClouds: 10,
+			want:      skyConfig{Clouds: 10},
+		},
+		{
+			name:      "default clouds over the limit",
+			query:     "",
+			maxClouds: 2,
+			want:      skyConfig{Clouds: 2},
+		},
+		{
+			name:      "at",
+			query:     "at=120,80&at=+300+,+200+",
+			maxClouds: defaultMaxClouds,
+			want: skyConfig{
+				Clouds: defaultClouds,
+				At:     []position{{X: 120, Y: 80}, {X: 300, Y: 200}},
+			},
+		},
+		{
+			name:      "malformed at",
+			query:     "at=120&at=a,b&at=1,&at=,2&at=3,4",
+			maxClouds: defaultMaxClouds,
+			want: skyConfig{
+				Clouds: defaultClouds,
+				At:     []position{{X: 3, Y: 4}},
+			},
+		},
+		{
+			name:      "at over the limit",
+			query:     "at=1,1&at=2,2&at=3,3",
+			maxClouds: 2,
+			want: skyConfig{
+				Clouds: 2,
+				At:     []position{{X: 1, Y: 1}, {X: 2, Y: 2}},
+			},
+		},
+		{
+			name:      "at with no clouds allowed",
+			query:     "at=1,1",
+			maxClouds: 0,
+			want:      skyConfig{Clouds: 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setMaxClouds(t, test.maxClouds)
+
+			q, err := url.ParseQuery(test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := parseSkyConfig(q); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseSkyConfig(%q) = %+v, want %+v", test.query, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSkyConfigPositions(t *testing.T) {
+	const w, h = 800, 600
+
+	tests := []struct {
+		name   string
+		config skyConfig
+	}{
+		{name: "scattered", config: skyConfig{Clouds: 7}},
+		{name: "unknown layout", config: skyConfig{Clouds: 7, Layout: "spiral"}},
+		{name: "row", config: skyConfig{Clouds: 7, Layout: "row"}},
+		{name: "grid", config: skyConfig{Clouds: 7, Layout: "grid"}},
+		{name: "no scattered clouds", config: skyConfig{Clouds: 0}},
+		{name: "no row clouds", config: skyConfig{Clouds: 0, Layout: "row"}},
+		{name: "no grid clouds", config: skyConfig{Clouds: 0, Layout: "grid"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			positions := test.config.positions(w, h)
+			if len(positions) != test.config.Clouds {
+				t.Fatalf("got %d positions, want %d", len(positions), test.config.Clouds)
+			}
+			for _, p := range positions {
+				if p.X < 0 || p.Y < 0 || p.X > w-cloudSize || p.Y > h-cloudSize {
+					t.Errorf("cloud at %+v is out of the %dx%d viewport", p, w, h)
+				}
+			}
+		})
+	}
+
+	t.Run("at", func(t *testing.T) {
+		at := []position{{X: 1, Y: 2}, {X: 3, Y: 4}}
+		got := skyConfig{Clouds: 7, Layout: "grid", At: at}.positions(w, h)
+		if !reflect.DeepEqual(got, at) {
+			t.Errorf("positions = %+v, want %+v", got, at)
+		}
+	})
+
+	t.Run("small viewport", func(t *testing.T) {
+		if got := scatteredLayout(3, cloudSize/2, cloudSize/2); len(got) != 3 {
+			t.Errorf("got %d positions, want 3", len(got))
+		}
+	})
+}
@@ -3,9 +3,10 @@ package main
 import (
 	"flag"
 	"log"
-	"math/rand"
 	"net/http"
+	"os"
 	"strconv"
+	"time"
 
 	"github.com/maxence-charriere/go-app/v10/pkg/app"
 )
@@ -16,62 +17,238 @@ import (
 type MovingClouds struct {
 	app.Compo
 	clouds []*draggableButton
+
+	// nextID is used to give every cloud created a unique component ID.
+	nextID int
+
+	// recorder records interactions when the page is opened with ?record.
+	recorder *sessionRecorder
+
+	// replaying reports whether the page was opened with ?replay, which lets
+	// a maintainer load and play back a recorded session.
+	replaying bool
+
+	// replay identifies the running replay. Incrementing it cancels the
+	// events left to play.
+	replay int
+
+	// auditing reports whether the page was opened with ?audit, which shows
+	// the accessibility audit overlay.
+	auditing bool
+
+	// animation identifies the running cloud animation. Incrementing it
+	// cancels the animation.
+	animation int
+
+	// undoLayouts are the layouts the clouds had before each arrangement,
+	// the most recent last.
+	undoLayouts [][]position
+
+	// hovering reports whether the pointer is over a cloud that can be
+	// dragged.
+	hovering bool
+
+	// scattering reports whether the "scatter along path" tool is active,
+	// duplicating the cloud at scatterSource along scatterPath.
+	scattering    bool
+	scatterSource int
+	scatterPath   []position
+}
+
+// OnPreRender places the clouds requested by the page URL so that the
+// server-side HTML already shows them in position. The viewport size is
+// unknown on the server, so a default size is assumed.
+func (mc *MovingClouds) OnPreRender(ctx app.Context) {
+	mc.placeClouds(ctx, parseSkyConfig(ctx.Page().URL().Query()).positions(defaultWidth, defaultHeight))
 }
 
-func (mc *MovingClouds) OnInit() {
-	mc.clouds = make([]*draggableButton, 4)
-	for i := range mc.clouds {
+// OnMount places the clouds requested by the page URL within the browser
+// window and, when asked for, starts the diagnostic session recording.
+func (mc *MovingClouds) OnMount(ctx app.Context) {
+	w, h := app.Window().Size()
+	if w <= 0 {
+		w = defaultWidth
+	}
+	if h <= 0 {
+		h = defaultHeight
+	}
+
+	query := ctx.Page().URL().Query()
+	positions := parseSkyConfig(query).positions(w, h)
+	if query.Has("record") {
+		mc.recorder = newSessionRecorder(positions, w, h)
+	}
+	mc.replaying = query.Has("replay")
+	mc.auditing = query.Has("audit")
+	mc.placeClouds(ctx, positions)
+	loadSpriteMask(cloudImage)
+
+	ctx.Handle(scatterAction, mc.onScatter)
+	ctx.Handle(replayAction, mc.onReplay)
+}
+
+// placeClouds replaces the current clouds with new ones at the given
+// positions.
+func (mc *MovingClouds) placeClouds(ctx app.Context, positions []position) {
+	mc.animation++
+	mc.undoLayouts = nil
+
+	mc.clouds = make([]*draggableButton, len(positions))
+	for i, p := range positions {
+		mc.nextID++
 		mc.clouds[i] = &draggableButton{
-			Image: "/web/cloud.png",
+			id:       strconv.Itoa(mc.nextID),
+			index:    i,
+			left:     p.X,
+			top:      p.Y,
+			recorder: mc.recorder,
+			canAdd:   mc.canAddCloud,
+			Image:    cloudImage,
 		}
 	}
+	ctx.Update()
 }
 
-func (mc *MovingClouds) OnMount(ctx app.Context) {
+// canAddCloud reports whether the sky has room for another cloud.
+func (mc *MovingClouds) canAddCloud() bool {
+	return len(mc.clouds) < maxClouds
+}
+
+// addCloud adds a cloud with the given image at p, unless the sky is full.
+func (mc *MovingClouds) addCloud(image string, p position) {
+	if !mc.canAddCloud() {
+		return
+	}
+
+	// Layouts to undo no longer cover every cloud.
+	mc.undoLayouts = nil
+
+	mc.nextID++
+	index := len(mc.clouds)
+	mc.clouds = append(mc.clouds, &draggableButton{
+		id:       strconv.Itoa(mc.nextID),
+		index:    index,
+		left:     p.X,
+		top:      p.Y,
+		recorder: mc.recorder,
+		canAdd:   mc.canAddCloud,
+		Image:    image,
+	})
+	mc.recorder.add("add", index, p.X, p.Y)
+	loadSpriteMask(image)
+}
+
+// positions returns the current position of every cloud.
+func (mc *MovingClouds) positions() []position {
+	positions := make([]position, len(mc.clouds))
+	for i, c := range mc.clouds {
+		positions[i] = position{X: c.left, Y: c.top}
+	}
+	return positions
+}
+
+// loadReplay restores the starting layout of a recorded session and plays
+// its events back.
+func (mc *MovingClouds) loadReplay(ctx app.Context, data string) {
+	log, err := parseSessionLog(data)
+	if err != nil {
+		errorf("replay", "loading session recording failed: %v", err)
+		return
+	}
+
+	clouds := log.Clouds
+	if len(clouds) > maxClouds {
+		warnf("replay", "limiting the %d recorded clouds to %d", len(clouds), maxClouds)
+		clouds = clouds[:maxClouds]
+	}
+	mc.placeClouds(ctx, clouds)
+	mc.scheduleReplay(ctx, log)
+}
+
+// onReplay performs the recorded interactions that target the sky rather
+// than a single cloud.
+func (mc *MovingClouds) onReplay(ctx app.Context, a app.Action) {
+	ev, ok := a.Value.(sessionEvent)
+	if !ok {
+		return
+	}
+
+	switch ev.Kind {
+	case "add":
+		mc.addCloud(cloudImage, position{X: ev.X, Y: ev.Y})
+	case "arrange":
+		mc.arrangeTo(ctx, ev.Layout)
+	case "undo":
+		if n := len(mc.undoLayouts); n != 0 {
+			mc.undoLayouts = mc.undoLayouts[:n-1]
+		}
+		mc.animateClouds(ctx, ev.Layout)
+	}
 }
 
 type draggableButton struct {
 	app.Compo
+	id          string
+	index       int
 	left        int
 	top         int
 	dragging    bool
 	offsetX     int
 	offsetY     int
+	locked      bool
+	menuOpen    bool
+	menuX       int
+	menuY       int
+	dialogOpen  bool
+	dialogX     int
+	dialogY     int
+	recorder    *sessionRecorder
+	canAdd      func() bool
 	Image       string
 	onMouseMove app.Func
 	onMouseUp   app.Func
 }
 
-func (b *draggableButton) OnMount(ctx app.Context) {
-	ctx.Dispatch(func(ctx app.Context) {
-		w, h := app.Window().Size()
-		if w <= 0 {
-			w = 800
-		}
-		if h <= 0 {
-			h = 600
-		}
+// CompoID makes go-app mount a new cloud rather than reuse the previous one
+// when the clouds are replaced.
+func (b *draggableButton) CompoID() string {
+	return b.id
+}
 
-		b.left = rand.Intn(w - 100)
-		b.top = rand.Intn(h - 100)
-		ctx.Update()
-	})
+func (b *draggableButton) OnMount(ctx app.Context) {
+	ctx.Handle(replayAction, b.onReplay)
+	ctx.Handle(moveAction, b.onMove)
+	ctx.Handle(pressAction, b.onPress)
+	ctx.Handle(menuAction, b.onMenu)
 }
 
 func (b *draggableButton) Render() app.UI {
+	label := "Cloud " + strconv.Itoa(b.index+1)
+	if b.locked {
+		label += ", locked"
+	}
+
+	// Mouse events go through the button to the sky, which resolves the
+	// cloud they target. See hitTest. Clicks only come from the keyboard,
+	// with Enter or Space, and open the menu like the context menu key.
 	btn := app.Button().
+		ID(b.buttonID()).
+		Class("cloud").
+		Aria("label", label).
+		Aria("haspopup", "menu").
 		Style("position", "absolute").
 		Style("left", strconv.Itoa(b.left)+"px").
 		Style("top", strconv.Itoa(b.top)+"px").
-		Style("cursor", "move").
-		OnMouseDown(b.startDrag)
+		Style("pointer-events", "none").
+		OnClick(b.openMenu).
+		OnContextMenu(b.openMenu)
 
 	if b.Image != "" {
 		btn = btn.Style("background-image", "url('"+b.Image+"')").
 			Style("background-size", "cover").
 			Style("background-position", "center").
-			Style("width", "100px").
-			Style("height", "100px").
+			Style("width", strconv.Itoa(cloudSize)+"px").
+			Style("height", strconv.Itoa(cloudSize)+"px").
 			Style("background-color", "transparent"). // Make background transparent
 			Style("border", "none").                  // Remove border
 			Text("")
@@ -79,16 +256,50 @@ func (b *draggableButton) Render() app.UI {
 		btn = btn.Text("Drag Me")
 	}
 
-	return btn
+	return app.Div().Body(
+		btn,
+		app.If(b.locked, b.renderLockBadge),
+		app.If(b.menuOpen, b.renderMenu),
+		app.If(b.dialogOpen, b.renderMoveDialog),
+	)
+}
+
+// setLocked locks or unlocks the cloud. A locked cloud can't be dragged nor
+// moved by an arrangement.
+func (b *draggableButton) setLocked(v bool) {
+	b.locked = v
+	if v {
+		b.recorder.add("lock", b.index, b.left, b.top)
+	} else {
+		b.recorder.add("unlock", b.index, b.left, b.top)
+	}
 }
 
-func (b *draggableButton) startDrag(ctx app.Context, e app.Event) {
+// press grabs the cloud at the given pointer position, in sky coordinates.
+func (b *draggableButton) press(x, y int) {
 	b.dragging = true
-	ev := e.JSValue()
-	clientX := ev.Get("clientX").Int()
-	clientY := ev.Get("clientY").Int()
-	b.offsetX = clientX - b.left
-	b.offsetY = clientY - b.top
+	b.offsetX = x - b.left
+	b.offsetY = y - b.top
+	b.recorder.add("down", b.index, x, y)
+}
+
+// drag moves a grabbed cloud along with the pointer.
+func (b *draggableButton) drag(x, y int) {
+	b.left = x - b.offsetX
+	b.top = y - b.offsetY
+	b.recorder.add("move", b.index, x, y)
+}
+
+// release drops the cloud.
+func (b *draggableButton) release(x, y int) {
+	b.dragging = false
+	b.recorder.add("up", b.index, x, y)
+}
+
+// startDrag grabs the cloud at the given pointer position, in sky
+// coordinates, and follows the pointer until the mouse button is released.
+func (b *draggableButton) startDrag(ctx app.Context, x, y int) {
+	b.press(x, y)
 
 	// Define callbacks
 	b.onMouseMove = app.FuncOf(func(this app.Value, args []app.Value) interface{} {
@@ -96,12 +307,19 @@ func (b *draggableButton) startDrag(ctx app.Context, e app.Event) {
 			return nil
 		}
 		event := args[0]
-		clientX := event.Get("clientX").Int()
-		clientY := event.Get("clientY").Int()
+		p := skyPosition(event.Get("clientX").Int(), event.Get("clientY").Int())
+
+		// The button may have been released before the drag started
+		// listening to mouseup.
+		if event.Get("buttons").Int()&1 == 0 {
+			ctx.Dispatch(func(ctx app.Context) {
+				b.endDrag(p.X, p.Y)
+			})
+			return nil
+		}
 
 		ctx.Dispatch(func(ctx app.Context) {
-			b.left = clientX - b.offsetX
-			b.top = clientY - b.offsetY
+			b.drag(p.X, p.Y)
 			// Trigger update
 			ctx.Update() // Calling Update() on the component itself
 		})
@@ -109,12 +327,11 @@ func (b *draggableButton) startDrag(ctx app.Context, e app.Event) {
 	})
 
 	b.onMouseUp = app.FuncOf(func(this app.Value, args []app.Value) interface{} {
-		b.dragging = false
+		event := args[0]
+		p := skyPosition(event.Get("clientX").Int(), event.Get("clientY").Int())
+
 		ctx.Dispatch(func(ctx app.Context) {
-			app.Window().JSValue().Call("removeEventListener", "mousemove", b.onMouseMove)
-			app.Window().JSValue().Call("removeEventListener", "mouseup", b.onMouseUp)
-			b.onMouseMove.Release()
-			b.onMouseUp.Release()
+			b.endDrag(p.X, p.Y)
 		})
 		return nil
 	})
@@ -124,18 +341,118 @@ func (b *draggableButton) startDrag(ctx app.Context, e app.Event) {
 	app.Window().JSValue().Call("addEventListener", "mouseup", b.onMouseUp)
 }
 
+// endDrag releases the cloud and stops following the pointer.
+func (b *draggableButton) endDrag(x, y int) {
+	if !b.dragging {
+		return
+	}
+
+	b.release(x, y)
+	app.Window().JSValue().Call("removeEventListener", "mousemove", b.onMouseMove)
+	app.Window().JSValue().Call("removeEventListener", "mouseup", b.onMouseUp)
+	b.onMouseMove.Release()
+	b.onMouseUp.Release()
+}
+
+// onReplay performs a recorded interaction targeting this cloud.
+func (b *draggableButton) onReplay(ctx app.Context, a app.Action) {
+	ev, ok := a.Value.(sessionEvent)
+	if !ok || ev.Cloud != b.index {
+		return
+	}
+
+	switch ev.Kind {
+	case "down":
+		b.press(ev.X, ev.Y)
+	case "move":
+		if b.dragging {
+			b.drag(ev.X, ev.Y)
+		}
+	case "up":
+		b.release(ev.X, ev.Y)
+	case "lock":
+		b.setLocked(true)
+	case "unlock":
+		b.setLocked(false)
+	}
+}
+
 // The Render method is where the component appearance is defined.
 func (mc *MovingClouds) Render() app.UI {
+	cursor := "default"
+	if mc.hovering {
+		cursor = "move"
+	}
+
 	return app.Div().
+		ID(skyID).
 		Style("background-image", "url('/web/moving-clouds.png')").
 		Style("background-size", "cover").
 		Style("background-position", "center").
 		Style("min-height", "100vh").
 		Style("position", "relative").
+		Style("cursor", cursor).
+		OnMouseDown(mc.onPointerDown).
+		OnMouseMove(mc.onPointerOver).
+		OnContextMenu(mc.onSkyContextMenu).
 		Body(
 			app.Range(mc.clouds).Slice(func(i int) app.UI {
 				return mc.clouds[i]
 			}),
+			app.Div().
+				ID("toolbar").
+				Style("position", "fixed").
+				Style("bottom", "8px").
+				Style("left", "8px").
+				Body(
+					app.Button().
+						Text("Arrange clouds").
+						OnClick(mc.arrangeClouds),
+					app.If(len(mc.undoLayouts) != 0, func() app.UI {
+						return app.Button().
+							Text("Undo").
+							OnClick(mc.undoArrange)
+					}),
+				),
+			app.If(mc.scattering, mc.renderScatterTool),
+			app.If(!mc.replaying, func() app.UI {
+				return &tour{
+					Name:  "onboarding",
+					Steps: onboardingSteps,
+				}
+			}),
+			app.If(mc.auditing, func() app.UI {
+				return &accessibilityAudit{}
+			}),
+			app.If(mc.recorder != nil, func() app.UI {
+				return app.Div().
+					Style("position", "fixed").
+					Style("top", "8px").
+					Style("right", "8px").
+					Body(
+						app.Text("Recording session "),
+						app.Button().
+							Text("Download recording").
+							OnClick(func(ctx app.Context, e app.Event) {
+								mc.recorder.download()
+							}),
+					)
+			}),
+			app.If(mc.replaying, func() app.UI {
+				return app.Div().
+					Style("position", "fixed").
+					Style("top", "8px").
+					Style("right", "8px").
+					Body(
+						app.Label().Text("Replay recording "),
+						app.Input().
+							Type("file").
+							Accept("application/json").
+							OnChange(func(ctx app.Context, e app.Event) {
+								readFile(ctx, ctx.JSSrc(), mc.loadReplay)
+							}),
+					)
+			}),
 		)
 }
 
@@ -149,6 +466,14 @@ func main() {
 	// component to display for a given path, on both client and server-side.
 	app.Route("/", func() app.Composer { return &MovingClouds{} })
 
+	// The debug console isn't linked from the app: users are pointed to it
+	// when reporting bugs.
+	app.Route("/debug", func() app.Composer { return &debugConsole{} })
+
+	if app.IsClient {
+		loadMaxClouds()
+	}
+
 	// Once the routes set up, the next thing to do is to either launch the app
 	// or the server that serves the app.
 	//
@@ -164,13 +489,38 @@ func main() {
 
 	// Define a flag to check if we should generate the static website
 	genStatic := flag.Bool("static", false, "Generate static website")
+
+	// Security headers are set on every response of the server. Passing an
+	// empty value disables a header.
+	var headers securityHeaders
+	flag.StringVar(&headers.ContentSecurityPolicy, "csp", defaultContentSecurityPolicy, "Content-Security-Policy header, completed with a frame-ancestors directive matching -frame-options unless it has one")
+	flag.StringVar(&headers.FrameOptions, "frame-options", defaultFrameOptions, "X-Frame-Options header, also applied to the Content-Security-Policy frame-ancestors directive")
+	flag.StringVar(&headers.ReferrerPolicy, "referrer-policy", defaultReferrerPolicy, "Referrer-Policy header")
+	flag.StringVar(&headers.PermissionsPolicy, "permissions-policy", defaultPermissionsPolicy, "Permissions-Policy header")
+
+	// Maintenance mode can also be enabled from the environment, which is
+	// where the bypass token is best kept.
+	var maintenance maintenanceMode
+	maintenanceEnv, _ := strconv.ParseBool(os.Getenv("MOVINGCLOUDS_MAINTENANCE"))
+	flag.BoolVar(&maintenance.Enabled, "maintenance", maintenanceEnv, "Serve a maintenance page on all routes except /healthz")
+	flag.DurationVar(&maintenance.RetryAfter, "maintenance-retry", 5*time.Minute, "How long clients are told to wait during maintenance")
+	flag.StringVar(&maintenance.Token, "maintenance-token", os.Getenv("MOVINGCLOUDS_MAINTENANCE_TOKEN"), "Token that bypasses maintenance mode")
+
+	receiveClientErrors := flag.Bool("client-errors", false, "Receive client warnings and errors into the server log")
+	flag.IntVar(&maxClouds, "max-clouds", defaultMaxClouds, "Maximum number of clouds in a sky")
 	flag.Parse()
 
+	if maxClouds < 0 {
+		log.Fatalf("invalid -max-clouds %d: must not be negative", maxClouds)
+	}
+
 	if *genStatic {
+		// Resources that aren't routes must be listed to be part of the static
+		// website.
 		err := app.GenerateStaticWebsite(".", &app.Handler{
 			Name:        "Moving Clouds Publishing",
 			Description: "A Moving Clouds Web Application",
-		})
+		}, "/robots.txt")
 
 		if err != nil {
 			log.Fatal(err)
@@ -183,12 +533,24 @@ func main() {
 	//
 	// The Handler is an HTTP handler that serves the client and all its
 	// required resources to make it work into a web browser. Here it is
-	// configured to handle requests with a path that starts with "/".
+	// configured to handle requests with a path that starts with "/", and
+	// wrapped to add security headers to its responses and to honor
+	// maintenance mode.
+
+	env := map[string]string{
+		maxCloudsEnv: strconv.Itoa(maxClouds),
+	}
+	if *receiveClientErrors {
+		env[clientErrorsEnv] = clientErrorsPath
+		http.Handle(clientErrorsPath, headers.wrap(maintenance.wrap(http.HandlerFunc(clientErrors))))
+	}
 
-	http.Handle("/", &app.Handler{
+	http.Handle("/", headers.wrap(maintenance.wrap(&app.Handler{
 		Name:        "Moving Clouds Publishing",
 		Description: "A Moving Clouds Web Application",
-	})
+		Env:         env,
+	})))
+	http.HandleFunc("/healthz", healthz)
 
 	if err := http.ListenAndServe(":8000", nil); err != nil {
 		log.Fatal(err)
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maintenanceCookie is the cookie that lets a browser bypass maintenance
+// mode once it visited a page with the maintenance token.
+const maintenanceCookie = "maintenance-token"
+
+// maintenancePage is the page served while in maintenance mode.
+const maintenancePage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Moving Clouds - Be right back</title>
+<style>
+	body {
+		margin: 0;
+		min-height: 100vh;
+		display: flex;
+		flex-direction: column;
+		align-items: center;
+		justify-content: center;
+		font-family: sans-serif;
+		color: #fff;
+		background: linear-gradient(#4a90d9, #a6d1f5);
+		text-shadow: 0 1px 2px rgba(0, 0, 0, 0.3);
+	}
+</style>
+</head>
+<body>
+<h1>Be right back</h1>
+<p>Moving Clouds is down for maintenance. Please check back in a few minutes.</p>
+</body>
+</html>
+`
+
+// maintenanceMode serves a "be right back" page with a 503 status to every
+// request, unless disabled or bypassed with the maintenance token.
+type maintenanceMode struct {
+	Enabled bool
+
+	// RetryAfter is the duration advertised to clients in the Retry-After
+	// header.
+	RetryAfter time.Duration
+
+	// Token lets admins use the site during maintenance by visiting any page
+	// with ?maintenance-token=<token>. The token is then kept in a cookie. An
+	// empty token disables the bypass.
+	Token string
+}
+
+// wrap returns a handler that calls h outside of maintenance mode, or for
+// requests that bypass it.
+func (m maintenanceMode) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.Enabled || m.bypass(w, r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Retry-After", strconv.Itoa(int(m.RetryAfter.Seconds())))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, maintenancePage)
+	})
+}
+
+// bypass reports whether the request carries the maintenance token, either
+// in the query or in the cookie set by a previous request.
+func (m maintenanceMode) bypass(w http.ResponseWriter, r *http.Request) bool {
+	if m.Token == "" {
+		return false
+	}
+
+	if token := r.URL.Query().Get(maintenanceCookie); m.validToken(token) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     maintenanceCookie,
+			Value:    token,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+		return true
+	}
+
+	c, err := r.Cookie(maintenanceCookie)
+	return err == nil && m.validToken(c.Value)
+}
+
+func (m maintenanceMode) validToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(m.Token)) == 1
+}
+
+// healthz reports that the server is up. It is never affected by
+// maintenance mode.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, "ok\n")
+}
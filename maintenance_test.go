@@ -0,0 +1,137 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newMaintenanceMux returns a server mux laid out like the one of main,
+// with the app replaced by a handler answering "app".
+func newMaintenanceMux(m maintenanceMode) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/", m.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "app")
+	})))
+	mux.HandleFunc("/healthz", healthz)
+	return mux
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	m := maintenanceMode{
+		Enabled:    true,
+		RetryAfter: 2 * time.Minute,
+		Token:      "secret",
+	}
+	noToken := m
+	noToken.Token = ""
+
+	tests := []struct {
+		name       string
+		mode       maintenanceMode
+		target     string
+		cookie     string
+		wantStatus int
+		wantCookie bool
+	}{
+		{
+			name:       "disabled",
+			mode:       maintenanceMode{Token: "secret"},
+			target:     "/",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "enabled",
+			mode:       m,
+			target:     "/",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "query token",
+			mode:       m,
+			target:     "/?maintenance-token=secret",
+			wantStatus: http.StatusOK,
+			wantCookie: true,
+		},
+		{
+			name:       "cookie token",
+			mode:       m,
+			target:     "/",
+			cookie:     "secret",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "wrong query token",
+			mode:       m,
+			target:     "/?maintenance-token=guess",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "wrong cookie token",
+			mode:       m,
+			target:     "/",
+			cookie:     "guess",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "empty query token",
+			mode:       m,
+			target:     "/?maintenance-token=",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "bypass disabled",
+			mode:       noToken,
+			target:     "/?maintenance-token=",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "healthz",
+			mode:       m,
+			target:     "/healthz",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, test.target, nil)
+			if test.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: maintenanceCookie, Value: test.cookie})
+			}
+			w := httptest.NewRecorder()
+			newMaintenanceMux(test.mode).ServeHTTP(w, r)
+
+			res := w.Result()
+			if res.StatusCode != test.wantStatus {
+				t.Fatalf("status is %d, want %d", res.StatusCode, test.wantStatus)
+			}
+
+			if test.wantStatus == http.StatusServiceUnavailable {
+				if got := res.Header.Get("Retry-After"); got != "120" {
+					t.Errorf("Retry-After is %q, want %q", got, "120")
+				}
+				if got := res.Header.Get("Cache-Control"); got != "no-store" {
+					t.Errorf("Cache-Control is %q, want %q", got, "no-store")
+				}
+			}
+
+			var cookie *http.Cookie
+			for _, c := range res.Cookies() {
+				if c.Name == maintenanceCookie {
+					cookie = c
+				}
+			}
+			switch {
+			case test.wantCookie && cookie == nil:
+				t.Errorf("no %s cookie set", maintenanceCookie)
+			case test.wantCookie && (cookie.Value != m.Token || !cookie.HttpOnly):
+				t.Errorf("cookie is %+v, want an HttpOnly cookie with the token", cookie)
+			case !test.wantCookie && cookie != nil:
+				t.Errorf("unexpected %s cookie set", maintenanceCookie)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+)
+
+// openMenu shows the cloud context menu over the cloud when it is requested
+// from the keyboard, with Enter, Space or the context menu key. Right-clicks
+// are resolved by the sky, see onMenu.
+func (b *draggableButton) openMenu(ctx app.Context, e app.Event) {
+	e.PreventDefault()
+	b.showMenu(ctx, b.left+cloudSize/2, b.top+cloudSize/2)
+}
+
+// showMenu shows the cloud context menu at the given sky coordinates and
+// focuses its first item.
+func (b *draggableButton) showMenu(ctx app.Context, x, y int) {
+	b.menuOpen = true
+	b.menuX = x
+	b.menuY = y
+	focusLater(ctx, "#"+b.menuID()+" [role=menuitem]:not([disabled])")
+}
+
+// closeMenu hides the cloud context menu.
+func (b *draggableButton) closeMenu(ctx app.Context, e app.Event) {
+	e.PreventDefault()
+	b.menuOpen = false
+}
+
+// onMenuKeyDown closes the menu with Escape, giving the focus back to the
+// cloud, and moves the focus between items with the arrow keys.
+func (b *draggableButton) onMenuKeyDown(ctx app.Context, e app.Event) {
+	var step int
+	switch e.JSValue().Get("key").String() {
+	case "Escape":
+		b.closeMenu(ctx, e)
+		focusLater(ctx, "#"+b.buttonID())
+		return
+	case "ArrowDown":
+		step = 1
+	case "ArrowUp":
+		step = -1
+	default:
+		ctx.PreventUpdate()
+		return
+	}
+
+	e.PreventDefault()
+	ctx.PreventUpdate()
+	doc := app.Window().Get("document")
+	items := doc.Call("querySelectorAll", "#"+b.menuID()+" [role=menuitem]:not([disabled])")
+	n := items.Length()
+	if n == 0 {
+		return
+	}
+
+	current := -1
+	for i := 0; i < n; i++ {
+		if items.Index(i).Equal(doc.Get("activeElement")) {
+			current = i
+			break
+		}
+	}
+	items.Index(((current+step)%n + n) % n).Call("focus")
+}
+
+// toggleLock locks or unlocks the cloud from the context menu.
+func (b *draggableButton) toggleLock(ctx app.Context, e app.Event) {
+	b.setLocked(!b.locked)
+	b.menuOpen = false
+	focusLater(ctx, "#"+b.buttonID())
+}
+
+// buttonID and menuID are the IDs of the cloud button and of its context
+// menu.
+func (b *draggableButton) buttonID() string { return "cloud-" + b.id }
+func (b *draggableButton) menuID() string   { return "cloud-menu-" + b.id }
+
+// focusLater focuses the element matching selector once the current update
+// is rendered.
+func focusLater(ctx app.Context, selector string) {
+	ctx.Defer(func(ctx app.Context) {
+		if el := app.Window().Get("document").Call("querySelector", selector); el.Truthy() {
+			el.Call("focus")
+		}
+	})
+}
+
+// startScatter asks the sky to duplicate this cloud along a path drawn by
+// the user.
+func (b *draggableButton) startScatter(ctx app.Context, e app.Event) {
+	b.menuOpen = false
+	ctx.NewActionWithValue(scatterAction, b.index)
+}
+
+// renderMenu returns the cloud context menu. A transparent backdrop covers
+// the page so that clicking anywhere else closes the menu.
+func (b *draggableButton) renderMenu() app.UI {
+	lockLabel := "Lock"
+	if b.locked {
+		lockLabel = "Unlock"
+	}
+	full := b.canAdd != nil && !b.canAdd()
+
+	return app.Div().Body(
+		app.Div().
+			Style("position", "fixed").
+			Style("inset", "0").
+			OnClick(b.closeMenu).
+			OnContextMenu(b.closeMenu),
+		app.Div().
+			ID(b.menuID()).
+			Role("menu").
+			Style("position", "absolute").
+			Style("left", strconv.Itoa(b.menuX)+"px").
+			Style("top", strconv.Itoa(b.menuY)+"px").
+			Style("display", "flex").
+			Style("flex-direction", "column").
+			Style("background-color", "white").
+			Style("border", "1px solid #ccc").
+			Style("box-shadow", "0 2px 6px rgba(0, 0, 0, 0.2)").
+			OnKeyDown(b.onMenuKeyDown).
+			Body(
+				app.Button().
+					Role("menuitem").
+					Text(lockLabel).
+					OnClick(b.toggleLock),
+				app.Button().
+					Role("menuitem").
+					Disabled(b.locked).
+					Text("Move to…").
+					OnClick(b.openMoveDialog),
+				app.Button().
+					Role("menuitem").
+					Disabled(full).
+					Text("Scatter along path…").
+					OnClick(b.startScatter),
+				app.If(full, func() app.UI {
+					return app.Small().
+						Style("padding", "2px 6px").
+						Style("max-width", "200px").
+						Text("This sky already has the maximum of " + strconv.Itoa(maxClouds) + " clouds.")
+				}),
+			),
+	)
+}
+
+// renderLockBadge returns the small badge shown on the corner of a locked
+// cloud.
+func (b *draggableButton) renderLockBadge() app.UI {
+	return app.Span().
+		Title("Locked").
+		Style("position", "absolute").
+		Style("left", strconv.Itoa(b.left+cloudSize-20)+"px").
+		Style("top", strconv.Itoa(b.top+4)+"px").
+		Style("pointer-events", "none").
+		Text("🔒")
+}
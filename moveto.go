@@ -0,0 +1,160 @@
+package main
+
+import (
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+)
+
+// movePreset is a named position offered by the "Move to" dialog.
+type movePreset struct {
+	Label    string
+	Position func(w, h int) position
+}
+
+// movePresets are the positions offered by the "Move to" dialog, computed
+// for a w by h viewport.
+var movePresets = []movePreset{
+	{
+		Label:    "Top left",
+		Position: func(w, h int) position { return position{} },
+	},
+	{
+		Label:    "Top right",
+		Position: func(w, h int) position { return position{X: w - cloudSize} },
+	},
+	{
+		Label:    "Center",
+		Position: func(w, h int) position { return position{X: (w - cloudSize) / 2, Y: (h - cloudSize) / 2} },
+	},
+	{
+		Label:    "Bottom left",
+		Position: func(w, h int) position { return position{Y: h - cloudSize} },
+	},
+	{
+		Label:    "Bottom right",
+		Position: func(w, h int) position { return position{X: w - cloudSize, Y: h - cloudSize} },
+	},
+}
+
+// openMoveDialog shows the "Move to" dialog, prefilled with the current
+// cloud position.
+func (b *draggableButton) openMoveDialog(ctx app.Context, e app.Event) {
+	b.menuOpen = false
+	b.dialogOpen = true
+	b.dialogX = b.left
+	b.dialogY = b.top
+	focusLater(ctx, "#"+b.dialogInputID())
+}
+
+// closeMoveDialog hides the "Move to" dialog without moving the cloud and
+// gives the focus back to the cloud.
+func (b *draggableButton) closeMoveDialog(ctx app.Context, e app.Event) {
+	b.dialogOpen = false
+	focusLater(ctx, "#"+b.buttonID())
+}
+
+// submitMoveDialog moves the cloud to the position entered in the "Move to"
+// dialog.
+func (b *draggableButton) submitMoveDialog(ctx app.Context, e app.Event) {
+	e.PreventDefault()
+	b.closeMoveDialog(ctx, e)
+	b.moveTo(position{X: b.dialogX, Y: b.dialogY})
+}
+
+// dialogInputID is the ID of the first input of the "Move to" dialog, which
+// is focused when the dialog opens.
+func (b *draggableButton) dialogInputID() string {
+	return "move-dialog-x-" + b.id
+}
+
+// moveTo moves the cloud to p as if it was dragged there, so the move is
+// recorded and replayed like any other drag.
+func (b *draggableButton) moveTo(p position) {
+	if b.locked {
+		return
+	}
+
+	b.press(b.left, b.top)
+	b.drag(p.X, p.Y)
+	b.release(p.X, p.Y)
+}
+
+// renderMoveDialog returns the modal "Move to" dialog.
+func (b *draggableButton) renderMoveDialog() app.UI {
+	w, h := app.Window().Size()
+	if w <= 0 {
+		w = defaultWidth
+	}
+	if h <= 0 {
+		h = defaultHeight
+	}
+
+	return app.Div().
+		Style("position", "fixed").
+		Style("inset", "0").
+		Style("display", "flex").
+		Style("align-items", "center").
+		Style("justify-content", "center").
+		Style("background-color", "rgba(0, 0, 0, 0.3)").
+		Body(
+			app.Form().
+				Role("dialog").
+				Aria("modal", true).
+				Aria("labelledby", "move-dialog-title-"+b.id).
+				Style("display", "flex").
+				Style("flex-direction", "column").
+				Style("gap", "8px").
+				Style("padding", "16px").
+				Style("background-color", "white").
+				OnSubmit(b.submitMoveDialog).
+				OnKeyDown(func(ctx app.Context, e app.Event) {
+					if e.JSValue().Get("key").String() == "Escape" {
+						b.closeMoveDialog(ctx, e)
+					}
+				}).
+				Body(
+					app.H2().
+						ID("move-dialog-title-"+b.id).
+						Style("margin", "0").
+						Text("Move cloud to"),
+					app.Label().Body(
+						app.Text("X "),
+						app.Input().
+							ID(b.dialogInputID()).
+							Type("number").
+							Value(b.dialogX).
+							OnChange(b.ValueTo(&b.dialogX)),
+					),
+					app.Label().Body(
+						app.Text("Y "),
+						app.Input().
+							Type("number").
+							Value(b.dialogY).
+							OnChange(b.ValueTo(&b.dialogY)),
+					),
+					app.Div().
+						Role("group").
+						Aria("label", "Presets").
+						Body(
+							app.Range(movePresets).Slice(func(i int) app.UI {
+								p := movePresets[i].Position(w, h)
+								return app.Button().
+									Type("button").
+									Text(movePresets[i].Label).
+									OnClick(func(ctx app.Context, e app.Event) {
+										b.dialogX = p.X
+										b.dialogY = p.Y
+									})
+							}),
+						),
+					app.Div().Body(
+						app.Button().
+							Type("submit").
+							Text("Move"),
+						app.Button().
+							Type("button").
+							Text("Cancel").
+							OnClick(b.closeMoveDialog),
+					),
+				),
+		)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+)
+
+// replayAction is the action posted for each recorded event during a replay.
+// Clouds handle it by performing the recorded interaction.
+const replayAction = "session/replay"
+
+// replayDelay is how long a replay waits before playing the first event, so
+// the recorded starting layout can be seen first.
+const replayDelay = 500 * time.Millisecond
+
+// sessionEvent is a single recorded interaction on a cloud.
+type sessionEvent struct {
+	// T is the time of the event, in milliseconds since the recording
+	// started.
+	T int64 `json:"t"`
+
+	// Kind is "down", "move" or "up" for pointer events, "lock" or
+	// "unlock" when the cloud is locked or unlocked, and "add" when the
+	// cloud is created. "arrange" and "undo" are recorded when every cloud is
+	// moved by an arrangement or its undoing.
+	Kind string `json:"k"`
+
+	// Cloud is the index of the cloud the event targets, or -1 when it
+	// targets every cloud.
+	Cloud int `json:"c"`
+
+	// X and Y are the pointer position in the sky, or the cloud position for
+	// other events.
+	X int `json:"x"`
+	Y int `json:"y"`
+
+	// Layout is where the clouds are moved by "arrange" and "undo" events.
+	Layout []position `json:"l,omitempty"`
+}
+
+// sessionLog is a recorded session, as downloaded by users and loaded by
+// maintainers for replay.
+type sessionLog struct {
+	// Version is the app version the session was recorded with. Replaying
+	// against another version may not reproduce the same behavior.
+	Version string `json:"version"`
+
+	// Width and Height are the window size at the start of the recording.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// Clouds are the cloud positions at the start of the recording.
+	Clouds []position `json:"clouds"`
+
+	Events []sessionEvent `json:"events"`
+}
+
+// sessionRecorder records cloud interactions once diagnostic recording is
+// enabled with the ?record query parameter. A nil recorder records nothing.
+type sessionRecorder struct {
+	start time.Time
+	log   sessionLog
+}
+
+// newSessionRecorder starts a recording from the given cloud positions.
+func newSessionRecorder(clouds []position, w, h int) *sessionRecorder {
+	return &sessionRecorder{
+		start: time.Now(),
+		log: sessionLog{
+			Version: app.Getenv("GOAPP_VERSION"),
+			Width:   w,
+			Height:  h,
+			Clouds:  clouds,
+		},
+	}
+}
+
+// add records an event of the given kind on the cloud at index cloud.
+func (r *sessionRecorder) add(kind string, cloud, x, y int) {
+	if r == nil {
+		return
+	}
+
+	r.log.Events = append(r.log.Events, sessionEvent{
+		T:     time.Since(r.start).Milliseconds(),
+		Kind:  kind,
+		Cloud: cloud,
+		X:     x,
+		Y:     y,
+	})
+}
+
+// addLayout records an event of the given kind that moves every cloud to
+// layout.
+func (r *sessionRecorder) addLayout(kind string, layout []position) {
+	if r == nil {
+		return
+	}
+
+	r.log.Events = append(r.log.Events, sessionEvent{
+		T:      time.Since(r.start).Milliseconds(),
+		Kind:   kind,
+		Cloud:  -1,
+		Layout: layout,
+	})
+}
+
+// download makes the browser save the recording as a JSON file.
+func (r *sessionRecorder) download() {
+	b, err := json.Marshal(r.log)
+	if err != nil {
+		errorf("record", "encoding session recording failed: %v", err)
+		return
+	}
+
+	blob := app.Window().Get("Blob").New(
+		[]any{string(b)},
+		map[string]any{"type": "application/json"},
+	)
+	href := app.Window().Get("URL").Call("createObjectURL", blob)
+	defer app.Window().Get("URL").Call("revokeObjectURL", href)
+
+	a := app.Window().Get("document").Call("createElement", "a")
+	a.Set("href", href)
+	a.Set("download", "moving-clouds-session.json")
+	a.Call("click")
+}
+
+// parseSessionLog decodes a recording and warns when it was made with
+// another app version.
+func parseSessionLog(data string) (sessionLog, error) {
+	var log sessionLog
+	if err := json.Unmarshal([]byte(data), &log); err != nil {
+		return sessionLog{}, err
+	}
+
+	if v := app.Getenv("GOAPP_VERSION"); log.Version != v {
+		warnf("replay", "replaying a session recorded with version %q on version %q", log.Version, v)
+	}
+	return log, nil
+}
+
+// scheduleReplay posts every recorded event as a replay action, at the same
+// pace as it was recorded. Scheduling another replay cancels the events left
+// from the previous one.
+func (mc *MovingClouds) scheduleReplay(ctx app.Context, log sessionLog) {
+	mc.replay++
+	replay := mc.replay
+
+	debugf("replay", "replaying %d events over %d clouds", len(log.Events), len(log.Clouds))
+	for _, ev := range log.Events {
+		d := replayDelay + time.Duration(ev.T)*time.Millisecond
+		ctx.After(d, func(ctx app.Context) {
+			if mc.replay != replay {
+				return
+			}
+			ctx.NewActionWithValue(replayAction, ev)
+		})
+	}
+}
+
+// readFile reads the first file selected in a file input and calls fn with
+// its content on the UI goroutine.
+func readFile(ctx app.Context, input app.Value, fn func(app.Context, string)) {
+	files := input.Get("files")
+	if files.Length() == 0 {
+		return
+	}
+
+	var onText app.Func
+	onText = app.FuncOf(func(this app.Value, args []app.Value) any {
+		text := args[0].String()
+		ctx.Dispatch(func(ctx app.Context) {
+			fn(ctx, text)
+		})
+		onText.Release()
+		return nil
+	})
+	files.Index(0).Call("text").Call("then", onText)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+)
+
+// scatterAction is the action posted by a cloud to start the "scatter along
+// path" tool. Its value is the index of the cloud to duplicate.
+const scatterAction = "cloud/scatter"
+
+const (
+	// scatterSpacing is the distance along the path between two duplicates,
+	// in pixels.
+	scatterSpacing = cloudSize * 4 / 5
+
+	// scatterJitter is the maximum random offset of a duplicate from the
+	// path, in pixels.
+	scatterJitter = cloudSize / 5
+)
+
+// pointsAlongPath returns points spaced evenly along a freehand path,
+// starting with its first point.
+func pointsAlongPath(path []position, spacing float64) []position {
+	if len(path) == 0 {
+		return nil
+	}
+
+	points := []position{path[0]}
+
+	// carried is the distance traveled since the last point, at the start
+	// of each segment.
+	carried := 0.0
+	for i := 1; i < len(path); i++ {
+		x0, y0 := float64(path[i-1].X), float64(path[i-1].Y)
+		x1, y1 := float64(path[i].X), float64(path[i].Y)
+		length := math.Hypot(x1-x0, y1-y0)
+
+		d := spacing - carried
+		for ; d <= length; d += spacing {
+			t := d / length
+			points = append(points, position{
+				X: int(math.Round(x0 + (x1-x0)*t)),
+				Y: int(math.Round(y0 + (y1-y0)*t)),
+			})
+		}
+		carried = length - (d - spacing)
+	}
+	return points
+}
+
+// onScatter starts the path tool for the cloud which index is the action
+// value.
+func (mc *MovingClouds) onScatter(ctx app.Context, a app.Action) {
+	source, ok := a.Value.(int)
+	if !ok || source < 0 || source >= len(mc.clouds) || !mc.canAddCloud() {
+		return
+	}
+
+	mc.scattering = true
+	mc.scatterSource = source
+	mc.scatterPath = nil
+}
+
+// startPath begins drawing the scatter path.
+func (mc *MovingClouds) startPath(ctx app.Context, e app.Event) {
+	if e.JSValue().Get("button").Int() != 0 {
+		return
+	}
+	mc.scatterPath = []position{eventSkyPosition(e)}
+}
+
+// extendPath adds the pointer position to the scatter path being drawn.
+func (mc *MovingClouds) extendPath(ctx app.Context, e app.Event) {
+	if len(mc.scatterPath) == 0 {
+		ctx.PreventUpdate()
+		return
+	}
+	mc.scatterPath = append(mc.scatterPath, eventSkyPosition(e))
+}
+
+// endPath duplicates the selected cloud along the drawn path and leaves the
+// path tool.
+func (mc *MovingClouds) endPath(ctx app.Context, e app.Event) {
+	if len(mc.scatterPath) == 0 {
+		return
+	}
+
+	source := mc.clouds[mc.scatterSource]
+	points := pointsAlongPath(mc.scatterPath, scatterSpacing)
+	if room := maxClouds - len(mc.clouds); len(points) > room {
+		infof("scatter", "limiting %d copies to %d to stay within %d clouds", len(points), room, maxClouds)
+		points = points[:room]
+	}
+	infof("scatter", "scattering %d copies of cloud %d along a %d point path", len(points), mc.scatterSource, len(mc.scatterPath))
+	for _, p := range points {
+		mc.addCloud(source.Image, position{
+			X: p.X - cloudSize/2 + rand.Intn(2*scatterJitter+1) - scatterJitter,
+			Y: p.Y - cloudSize/2 + rand.Intn(2*scatterJitter+1) - scatterJitter,
+		})
+	}
+	mc.stopScatter(ctx, e)
+}
+
+// stopScatter leaves the path tool.
+func (mc *MovingClouds) stopScatter(ctx app.Context, e app.Event) {
+	e.PreventDefault()
+	mc.scattering = false
+	mc.scatterPath = nil
+}
+
+// renderScatterTool returns the overlay that captures the scatter path and
+// the path drawn so far. The overlay covers the window while the path, in sky
+// coordinates, is drawn over the sky so it lines up with the clouds.
+func (mc *MovingClouds) renderScatterTool() app.UI {
+	points := make([]string, len(mc.scatterPath))
+	for i, p := range mc.scatterPath {
+		points[i] = fmt.Sprintf("%d,%d", p.X, p.Y)
+	}
+
+	return app.Div().Body(
+		app.Div().
+			Style("position", "fixed").
+			Style("inset", "0").
+			Style("cursor", "crosshair").
+			OnMouseDown(mc.startPath).
+			OnMouseMove(mc.extendPath).
+			OnMouseUp(mc.endPath).
+			OnContextMenu(mc.stopScatter).
+			Body(
+				app.Div().
+					Style("position", "absolute").
+					Style("top", "8px").
+					Style("left", "50%").
+					Style("transform", "translateX(-50%)").
+					Style("padding", "4px 8px").
+					Style("background-color", "white").
+					Style("pointer-events", "none").
+					Text("Draw a path to scatter the cloud along it. Right-click to cancel."),
+			),
+		app.Raw(`<svg style="position: absolute; inset: 0; width: 100%; height: 100%; overflow: visible; pointer-events: none">`+
+			`<polyline points="`+strings.Join(points, " ")+`" fill="none" stroke="white" stroke-width="3" stroke-dasharray="6 4"/>`+
+			`</svg>`),
+	)
+}
+
+// eventPosition returns the client coordinates of a mouse event.
+func eventPosition(e app.Event) position {
+	ev := e.JSValue()
+	return position{
+		X: ev.Get("clientX").Int(),
+		Y: ev.Get("clientY").Int(),
+	}
+}
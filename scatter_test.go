@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPointsAlongPath(t *testing.T) {
+	// evenly returns n points spaced by step along the X axis.
+	evenly := func(n, step int) []position {
+		points := make([]position, n)
+		for i := range points {
+			points[i] = position{X: i * step}
+		}
+		return points
+	}
+
+	tests := []struct {
+		name    string
+		path    []position
+		spacing float64
+		want    []position
+	}{
+		{
+			name:    "empty",
+			spacing: 80,
+		},
+		{
+			name:    "single point",
+			path:    []position{{X: 10, Y: 20}},
+			spacing: 80,
+			want:    []position{{X: 10, Y: 20}},
+		},
+		{
+			name:    "straight segment",
+			path:    []position{{}, {X: 200}},
+			spacing: 80,
+			want:    evenly(3, 80),
+		},
+		{
+			name:    "shorter than the spacing",
+			path:    []position{{}, {X: 30, Y: 40}},
+			spacing: 80,
+			want:    []position{{}},
+		},
+		{
+			name:    "distance carried across a corner",
+			path:    []position{{}, {X: 50}, {X: 50, Y: 100}},
+			spacing: 80,
+			want:    []position{{}, {X: 50, Y: 30}},
+		},
+		{
+			name:    "distance carried across many segments",
+			path:    []position{{}, {X: 7}, {X: 19}, {X: 50}, {X: 203}},
+			spacing: 25,
+			want:    evenly(9, 25),
+		},
+		{
+			name:    "zero length segments",
+			path:    []position{{}, {}, {X: 30}, {X: 30}, {X: 100}, {X: 100}},
+			spacing: 40,
+			want:    evenly(3, 40),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := pointsAlongPath(test.path, test.spacing); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("pointsAlongPath = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/maxence-charriere/go-app/v10/pkg/app"
+)
+
+// tourPadding is the space left around a highlighted element, in pixels.
+const tourPadding = 8
+
+// tourStep is a step of a guided tour.
+type tourStep struct {
+	// Target is a CSS selector of the element highlighted by the step. When
+	// empty or when no element matches, the step is shown in the middle of
+	// the page without a spotlight.
+	Target string
+
+	Title string
+	Text  string
+}
+
+// onboardingSteps is the tour shown on the first visit.
+var onboardingSteps = []tourStep{
+	{
+		Title: "Welcome to Moving Clouds",
+		Text:  "This is your sky. Let's take a quick look around.",
+	},
+	{
+		Target: ".cloud",
+		Title:  "Move clouds",
+		Text:   "Drag a cloud to move it. Right-click it to lock it, move it to a precise position or scatter copies along a path.",
+	},
+	{
+		Target: "#toolbar",
+		Title:  "Arrange the sky",
+		Text:   "Spread all the clouds out so none overlap. You can undo it if you liked it better before.",
+	},
+}
+
+// tour is a guided tour that highlights page elements one step at a time.
+// Once completed or dismissed, it is never shown again on the device.
+type tour struct {
+	app.Compo
+
+	// Name identifies the tour in local storage.
+	Name string
+
+	Steps []tourStep
+
+	visible bool
+	step    int
+
+	// spotlight is the highlighted area of the current step. Its width is
+	// zero when the step has no target.
+	spotlightX int
+	spotlightY int
+	spotlightW int
+	spotlightH int
+}
+
+func (t *tour) OnMount(ctx app.Context) {
+	var done bool
+	ctx.LocalStorage().Get(t.storageKey(), &done)
+	if done || len(t.Steps) == 0 {
+		return
+	}
+
+	t.visible = true
+	t.step = 0
+
+	// Targets may still be rendering, so measuring waits for the current
+	// update cycle to complete.
+	ctx.Defer(func(ctx app.Context) {
+		t.measure(ctx)
+		ctx.Update()
+	})
+}
+
+func (t *tour) OnResize(ctx app.Context) {
+	if t.visible {
+		t.measure(ctx)
+	}
+}
+
+func (t *tour) storageKey() string {
+	return "/movingclouds/tour/" + t.Name
+}
+
+// measure locates the element highlighted by the current step.
+func (t *tour) measure(ctx app.Context) {
+	t.spotlightW = 0
+	t.spotlightH = 0
+
+	target := t.Steps[t.step].Target
+	if target == "" {
+		return
+	}
+
+	elem := app.Window().Get("document").Call("querySelector", target)
+	if !elem.Truthy() {
+		return
+	}
+
+	rect := elem.Call("getBoundingClientRect")
+	t.spotlightX = rect.Get("left").Int() - tourPadding
+	t.spotlightY = rect.Get("top").Int() - tourPadding
+	t.spotlightW = rect.Get("width").Int() + 2*tourPadding
+	t.spotlightH = rect.Get("height").Int() + 2*tourPadding
+}
+
+// goTo shows the step at index i, or completes the tour when i is past the
+// last step.
+func (t *tour) goTo(ctx app.Context, i int) {
+	if i >= len(t.Steps) {
+		t.dismiss(ctx)
+		return
+	}
+
+	t.step = max(i, 0)
+	t.measure(ctx)
+}
+
+// dismiss hides the tour for good.
+func (t *tour) dismiss(ctx app.Context) {
+	t.visible = false
+	if err := ctx.LocalStorage().Set(t.storageKey(), true); err != nil {
+		warnf("tour", "saving tour completion failed: %v", err)
+	}
+}
+
+func (t *tour) onKeyDown(ctx app.Context, e app.Event) {
+	if e.JSValue().Get("key").String() == "Escape" {
+		t.dismiss(ctx)
+	}
+}
+
+func (t *tour) Render() app.UI {
+	if !t.visible {
+		return app.Div()
+	}
+
+	step := t.Steps[t.step]
+	last := t.step == len(t.Steps)-1
+	nextLabel := "Next"
+	if last {
+		nextLabel = "Done"
+	}
+
+	// Without a target, the whole page is dimmed and the card is centered.
+	// Otherwise, the card is placed below the spotlight, or above it when
+	// it is close to the bottom of the window.
+	card := app.Div().
+		Role("dialog").
+		Aria("labelledby", "tour-title").
+		Style("position", "fixed").
+		Style("max-width", "320px").
+		Style("padding", "16px").
+		Style("background-color", "white").
+		Style("box-shadow", "0 2px 8px rgba(0, 0, 0, 0.3)").
+		OnKeyDown(t.onKeyDown)
+
+	var spotlight app.UI = app.Div().
+		Style("position", "fixed").
+		Style("inset", "0").
+		Style("background-color", "rgba(0, 0, 0, 0.5)")
+
+	if t.spotlightW == 0 {
+		card = card.
+			Style("left", "50%").
+			Style("top", "50%").
+			Style("transform", "translate(-50%, -50%)")
+	} else {
+		spotlight = app.Div().
+			Style("position", "fixed").
+			Style("left", strconv.Itoa(t.spotlightX)+"px").
+			Style("top", strconv.Itoa(t.spotlightY)+"px").
+			Style("width", strconv.Itoa(t.spotlightW)+"px").
+			Style("height", strconv.Itoa(t.spotlightH)+"px").
+			Style("border-radius", "8px").
+			Style("box-shadow", "0 0 0 9999px rgba(0, 0, 0, 0.5)").
+			Style("pointer-events", "none")
+
+		card = card.Style("left", strconv.Itoa(max(t.spotlightX, 8))+"px")
+		if _, h := app.Window().Size(); t.spotlightY+t.spotlightH > h*2/3 {
+			card = card.Style("bottom", strconv.Itoa(h-t.spotlightY+8)+"px")
+		} else {
+			card = card.Style("top", strconv.Itoa(t.spotlightY+t.spotlightH+8)+"px")
+		}
+	}
+
+	return app.Div().Body(
+		spotlight,
+		card.Body(
+			app.H2().
+				ID("tour-title").
+				Style("margin", "0 0 8px").
+				Text(step.Title),
+			app.P().Text(step.Text),
+			app.Div().
+				Style("display", "flex").
+				Style("gap", "8px").
+				Body(
+					app.Button().
+						Text("Skip tour").
+						OnClick(func(ctx app.Context, e app.Event) {
+							t.dismiss(ctx)
+						}),
+					app.If(t.step > 0, func() app.UI {
+						return app.Button().
+							Text("Back").
+							OnClick(func(ctx app.Context, e app.Event) {
+								t.goTo(ctx, t.step-1)
+							})
+					}),
+					app.Button().
+						AutoFocus(true).
+						Text(nextLabel).
+						OnClick(func(ctx app.Context, e app.Event) {
+							t.goTo(ctx, t.step+1)
+						}),
+				),
+		),
+	)
+}